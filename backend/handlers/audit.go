@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultAuditPage = 100
+	maxAuditPage     = 500
+)
+
+// GetAuditLog pages through a committee's audit chain. Pass the last
+// sequence number seen as ?after= to continue.
+func GetAuditLog(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil || after < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid after"})
+		return
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultAuditPage)), 10, 64)
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	if limit > maxAuditPage {
+		limit = maxAuditPage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
+	entries, err := services.ListAudit(ctx, services.AuditChain(committeeID), after, limit)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// VerifyAuditLog recomputes the committee's whole chain and reports the
+// first broken link, if any.
+func VerifyAuditLog(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
+	verification, err := services.VerifyAuditChain(ctx, services.AuditChain(committeeID))
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
@@ -1,13 +1,378 @@
 package handlers
 
-func GetComittee() {
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func CreateCommittee(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req models.CreateCommitteeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chairID := userID
+	if req.ChairID != "" {
+		chairID, err = primitive.ObjectIDFromHex(req.ChairID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chair ID"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if chairID != userID {
+		if err := ensureUserExists(ctx, chairID); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "chair not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+	}
+
+	now := time.Now()
+	committee := models.Committee{
+		ID:          primitive.NewObjectID(),
+		Name:        req.Name,
+		Type:        req.Type,
+		OwnerID:     userID,
+		ChairID:     chairID,
+		MemberIDs:   []primitive.ObjectID{},
+		ObserverIDs: []primitive.ObjectID{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	_, err = config.GetCollection("committees").InsertOne(ctx, committee)
+	if err != nil {
+		log.Printf("Error creating committee: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create committee"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, committee)
+}
+
+func GetCommittee(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	committee, role, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"committee":    committee,
+		"role":         role,
+		"capabilities": services.Capabilities(role),
+	})
 }
 
-func UpdateComittee() {
+func GetMyCommittees(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"$or": []bson.M{
+			{"owner_id": userID},
+			{"chair_id": userID},
+			{"member_ids": userID},
+			{"observer_ids": userID},
+		},
+	}
+	if c.Query("archived") != "true" {
+		filter["archived"] = bson.M{"$ne": true}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := config.GetCollection("committees").Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error fetching committees: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch committees"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var committees []models.Committee
+	if err = cursor.All(ctx, &committees); err != nil {
+		log.Printf("Error decoding committees: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode committees"})
+		return
+	}
+
+	results := make([]gin.H, 0, len(committees))
+	for _, committee := range committees {
+		results = append(results, gin.H{
+			"committee": committee,
+			"role":      services.RoleOf(&committee, userID),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"committees": results})
+}
+
+func UpdateCommittee(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	var req models.UpdateCommitteeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	committee, role, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapManageCommittee)
+	if !ok {
+		return
+	}
+
+	updateDoc := bson.M{}
+	unsetDoc := bson.M{}
+	if req.Name != nil {
+		if *req.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return
+		}
+		updateDoc["name"] = *req.Name
+	}
+	if req.Type != nil {
+		updateDoc["type"] = *req.Type
+	}
+	if req.ChairID != nil {
+		if !services.Can(role, services.CapAssignChair) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the owner can change the chair"})
+			return
+		}
+
+		chairID, err := primitive.ObjectIDFromHex(*req.ChairID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chair ID"})
+			return
+		}
+
+		if err := ensureUserExists(ctx, chairID); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "chair not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		updateDoc["chair_id"] = chairID
+	}
+	if req.SecretaryID != nil {
+		if *req.SecretaryID == "" {
+			unsetDoc["secretary_id"] = ""
+		} else {
+			secretaryID, err := primitive.ObjectIDFromHex(*req.SecretaryID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid secretary ID"})
+				return
+			}
+			if memberRole := services.RoleOf(committee, secretaryID); memberRole != models.CommitteeRoleMember && memberRole != models.CommitteeRoleSecretary {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the secretary must be a voting member"})
+				return
+			}
+			updateDoc["secretary_id"] = secretaryID
+		}
+	}
+
+	if len(updateDoc) == 0 && len(unsetDoc) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+	updateDoc["updated_at"] = time.Now()
+
+	update := bson.M{"$set": updateDoc}
+	if len(unsetDoc) > 0 {
+		update["$unset"] = unsetDoc
+	}
+
+	collection := config.GetCollection("committees")
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": committeeID}, update)
+	if err != nil {
+		log.Printf("Error updating committee: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update committee"})
+		return
+	}
+
+	var updated models.Committee
+	if err := collection.FindOne(ctx, bson.M{"_id": committeeID}).Decode(&updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch updated committee"})
+		return
+	}
+
+	applyRoleChanges(ctx, committee, &updated, userID)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func ArchiveCommittee(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapArchiveCommittee); !ok {
+		return
+	}
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"archived":    true,
+			"archived_at": now,
+			"updated_at":  now,
+		},
+	}
+
+	_, err = config.GetCollection("committees").UpdateOne(ctx, bson.M{"_id": committeeID}, update)
+	if err != nil {
+		log.Printf("Error archiving committee: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to archive committee"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "committee archived"})
+}
+
+func DeleteCommittee(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapDeleteCommittee); !ok {
+		return
+	}
+
+	if err := services.DeleteCommittee(ctx, committeeID); err != nil {
+		log.Printf("Error deleting committee: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete committee"})
+		return
+	}
+	wsHub.CloseRoom(models.CreateCommitteeRoomID(committeeID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "committee deleted"})
+}
+
+func authorizeCommittee(c *gin.Context, ctx context.Context, committeeID, userID primitive.ObjectID, capability services.Capability) (*models.Committee, models.CommitteeRole, bool) {
+	committee, role, err := services.Authorize(ctx, committeeID, userID, capability)
+	if err != nil {
+		respondServiceError(c, err)
+		return nil, role, false
+	}
+	return committee, role, true
+}
 
+func ensureUserExists(ctx context.Context, userID primitive.ObjectID) error {
+	return config.GetCollection("users").FindOne(ctx, bson.M{"_id": userID}).Err()
 }
 
-func DeleteComittee() {
+// applyRoleChanges logs the chair or secretary changes an update made, for
+// both the member who took the role and the one who gave it up. A former
+// chair who is not otherwise on the roster also loses the committee room.
+func applyRoleChanges(ctx context.Context, before, after *models.Committee, actorID primitive.ObjectID) {
+	affected := []primitive.ObjectID{before.ChairID, after.ChairID}
+	if before.SecretaryID != nil {
+		affected = append(affected, *before.SecretaryID)
+	}
+	if after.SecretaryID != nil {
+		affected = append(affected, *after.SecretaryID)
+	}
 
+	seen := make(map[primitive.ObjectID]bool)
+	for _, memberID := range affected {
+		if memberID.IsZero() || seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+		from, to := services.RoleOf(before, memberID), services.RoleOf(after, memberID)
+		if from == to {
+			continue
+		}
+		services.AuditRoleChange(ctx, after.ID, actorID, memberID, from, to)
+		if to == models.CommitteeRoleNone {
+			wsHub.RemoveUserFromRoom(memberID, models.CreateCommitteeRoomID(after.ID))
+		}
+	}
 }
@@ -1,13 +1,219 @@
 package handlers
 
-func GetComment() {
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CreateCommentRequest struct {
+	Content  string        `json:"content" binding:"required"`
+	Stance   models.Stance `json:"stance" binding:"required"`
+	ParentID string        `json:"parentId,omitempty"`
+}
+
+type UpdateCommentRequest struct {
+	Content *string        `json:"content,omitempty"`
+	Stance  *models.Stance `json:"stance,omitempty"`
 }
 
-func UpdateComment() {
+func GetMotionComments(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	comments, err := services.ListComments(ctx, motion.ID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"comments":     services.BuildCommentThreads(comments),
+		"stanceCounts": services.CountStances(comments),
+		"debateOpen":   services.DebateOpen(motion),
+	})
 }
 
-func DeleteComment() {
+func CreateComment(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input := services.PostCommentInput{
+		Content: req.Content,
+		Stance:  req.Stance,
+	}
+	if req.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(req.ParentID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parent comment ID"})
+			return
+		}
+		input.ParentID = &parentID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	comment, err := services.PostComment(ctx, motion, userID, input)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	broadcastComment(ctx, "comment_posted", motion, comment)
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+func GetComment(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, comment, ok := loadComment(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+func UpdateComment(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Content == nil && req.Stance == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, comment, ok := loadComment(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	updated, err := services.EditComment(ctx, motion, comment, userID, req.Content, req.Stance)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	broadcastComment(ctx, "comment_edited", motion, updated)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func DeleteComment(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, comment, ok := loadComment(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteComment(ctx, motion, comment, userID); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	broadcastComment(ctx, "comment_deleted", motion, comment)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"commentId": comment.ID,
+	})
+}
+
+func loadComment(c *gin.Context, ctx context.Context, userID primitive.ObjectID) (*models.Motion, *models.Comment, bool) {
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return nil, nil, false
+	}
+
+	commentID, err := primitive.ObjectIDFromHex(c.Param("commentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+		return nil, nil, false
+	}
+
+	comment, err := services.GetComment(ctx, commentID)
+	if err != nil {
+		respondServiceError(c, err)
+		return nil, nil, false
+	}
+
+	if comment.MotionID != motion.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		return nil, nil, false
+	}
+
+	return motion, comment, true
+}
 
+func broadcastComment(ctx context.Context, action string, motion *models.Motion, comment *models.Comment) {
+	counts, err := services.MotionStanceCounts(ctx, motion.ID)
+	if err != nil {
+		log.Printf("Error counting stances: %v", err)
+	}
+	wsHub.BroadcastComment(action, motion, comment, counts)
 }
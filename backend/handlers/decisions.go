@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultDecisionPage = 50
+	maxDecisionPage     = 200
+)
+
+type DecisionSummaryRequest struct {
+	Rationale string   `json:"rationale" binding:"required"`
+	Pros      []string `json:"pros"`
+	Cons      []string `json:"cons"`
+}
+
+// GetDecisions searches the committee's decided motions, newest first. Pass
+// the tally's decided_at of the last decision seen as ?before= to continue.
+func GetDecisions(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	query := services.DecisionQuery{Keyword: c.Query("q")}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseQueryDate(from, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+			return
+		}
+		query.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseQueryDate(to, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+			return
+		}
+		query.To = &t
+	}
+	if status := c.Query("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			query.Statuses = append(query.Statuses, models.MotionStatus(s))
+		}
+	}
+	if before := c.Query("before"); before != "" {
+		t, err := time.Parse(time.RFC3339Nano, before)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before"})
+			return
+		}
+		query.Before = &t
+	}
+	query.Limit, err = strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultDecisionPage)), 10, 64)
+	if err != nil || query.Limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	if query.Limit > maxDecisionPage {
+		query.Limit = maxDecisionPage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
+	decisions, err := services.ListDecisions(ctx, committeeID, query)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"decisions": decisions})
+}
+
+func GetDecision(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	decision, err := services.GetDecision(ctx, motion)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, decision)
+}
+
+func WriteDecisionSummary(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req DecisionSummaryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	updated, err := services.WriteDecisionSummary(ctx, motion, userID, services.SummaryInput{
+		Rationale: req.Rationale,
+		Pros:      req.Pros,
+		Cons:      req.Cons,
+	})
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMotionEvent("decision_summary_updated", updated, "")
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// parseQueryDate accepts RFC 3339 timestamps or plain dates. A plain date used
+// as the end of a range covers the whole day.
+func parseQueryDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/services"
+)
+
+var errorStatus = map[services.ErrorClass]int{
+	services.ErrorNotFound:   http.StatusNotFound,
+	services.ErrorPermission: http.StatusForbidden,
+	services.ErrorValidation: http.StatusBadRequest,
+	services.ErrorConflict:   http.StatusConflict,
+}
+
+func respondServiceError(c *gin.Context, err error) {
+	status, ok := errorStatus[services.ClassifyError(err)]
+	if !ok {
+		log.Printf("Service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func ScheduleMeeting(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	var req models.ScheduleMeetingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	agenda := make([]services.AgendaItemInput, 0, len(req.Agenda))
+	for _, item := range req.Agenda {
+		motionID, err := parseOptionalObjectID(item.MotionID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid agenda motion ID"})
+			return
+		}
+		agenda = append(agenda, services.AgendaItemInput{
+			Title:       item.Title,
+			Description: item.Description,
+			MotionID:    motionID,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	meeting, err := services.ScheduleMeeting(ctx, committeeID, userID, req.Title, req.ScheduledFor, agenda)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMeetingEvent("meeting_scheduled", meeting)
+
+	c.JSON(http.StatusCreated, meeting)
+}
+
+func GetMeetings(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
+	var statuses []models.MeetingStatus
+	if status := c.Query("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			statuses = append(statuses, models.MeetingStatus(s))
+		}
+	}
+
+	meetings, err := services.ListMeetings(ctx, committeeID, statuses)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"meetings": meetings})
+}
+
+func GetMeeting(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	meeting, ok := loadMeeting(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, meeting)
+}
+
+func CallMeetingToOrder(c *gin.Context) {
+	changeMeeting(c, "meeting_called_to_order", services.CallToOrder)
+}
+
+func AdvanceAgenda(c *gin.Context) {
+	changeMeeting(c, "agenda_advanced", services.AdvanceAgenda)
+}
+
+func AdjournMeeting(c *gin.Context) {
+	changeMeeting(c, "meeting_adjourned", services.AdjournMeeting)
+}
+
+func CheckInToMeeting(c *gin.Context) {
+	changeMeeting(c, "member_checked_in", services.CheckIn)
+}
+
+func CheckOutOfMeeting(c *gin.Context) {
+	changeMeeting(c, "member_checked_out", services.CheckOut)
+}
+
+func GetQuorum(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
+	status, err := services.CommitteeQuorum(ctx, committeeID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quorum": status})
+}
+
+func changeMeeting(c *gin.Context, action string, change func(context.Context, primitive.ObjectID, primitive.ObjectID) (*models.Meeting, error)) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	meeting, ok := loadMeeting(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	updated, err := change(ctx, meeting.ID, userID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMeetingEvent(action, updated)
+	wsHub.RefreshQuorum(updated.CommitteeID)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func loadMeeting(c *gin.Context, ctx context.Context, userID primitive.ObjectID) (*models.Meeting, bool) {
+	meetingID, err := primitive.ObjectIDFromHex(c.Param("meetingId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid meeting ID"})
+		return nil, false
+	}
+
+	meeting, err := services.GetMeeting(ctx, meetingID)
+	if err != nil {
+		respondServiceError(c, err)
+		return nil, false
+	}
+
+	if meeting.CommitteeID.Hex() != c.Param("id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "meeting not found"})
+		return nil, false
+	}
+
+	if _, _, ok := authorizeCommittee(c, ctx, meeting.CommitteeID, userID, services.CapViewCommittee); !ok {
+		return nil, false
+	}
+
+	return meeting, true
+}
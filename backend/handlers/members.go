@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var notificationService = &NotificationService{}
+
+func InviteCommitteeMember(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	var req models.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = models.CommitteeRoleMember
+	}
+	if req.Role != models.CommitteeRoleMember && req.Role != models.CommitteeRoleObserver {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be member or observer"})
+		return
+	}
+
+	inviteeID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid invitee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	committee, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapManageMembers)
+	if !ok {
+		return
+	}
+
+	if services.RoleOf(committee, inviteeID) != models.CommitteeRoleNone {
+		c.JSON(http.StatusConflict, gin.H{"error": "user is already part of this committee"})
+		return
+	}
+
+	if err := ensureUserExists(ctx, inviteeID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	collection := config.GetCollection("committee_invitations")
+	err = collection.FindOne(ctx, bson.M{
+		"committee_id": committeeID,
+		"invitee_id":   inviteeID,
+		"status":       models.InvitationStatusPending,
+	}).Err()
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "invitation already pending"})
+		return
+	} else if err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	invitation := models.CommitteeInvitation{
+		ID:          primitive.NewObjectID(),
+		CommitteeID: committeeID,
+		InviterID:   userID,
+		InviteeID:   inviteeID,
+		Role:        req.Role,
+		Status:      models.InvitationStatusPending,
+		CreatedAt:   time.Now(),
+	}
+
+	if _, err := collection.InsertOne(ctx, invitation); err != nil {
+		log.Printf("Error creating committee invitation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invitation"})
+		return
+	}
+
+	if err := notificationService.CreateCommitteeInvitationNotification(invitation, *committee); err != nil {
+		log.Printf("Error creating invitation notification: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+func GetCommitteeInvitations(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapManageMembers); !ok {
+		return
+	}
+
+	filter := bson.M{
+		"committee_id": committeeID,
+		"status":       models.InvitationStatusPending,
+	}
+	invitations, err := findInvitations(ctx, filter)
+	if err != nil {
+		log.Printf("Error fetching committee invitations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+func GetMyInvitations(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"invitee_id": userID,
+		"status":     models.InvitationStatusPending,
+	}
+	invitations, err := findInvitations(ctx, filter)
+	if err != nil {
+		log.Printf("Error fetching invitations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch invitations"})
+		return
+	}
+
+	committeeIDs := make([]primitive.ObjectID, 0, len(invitations))
+	for _, invitation := range invitations {
+		committeeIDs = append(committeeIDs, invitation.CommitteeID)
+	}
+
+	committeeNames := make(map[primitive.ObjectID]string)
+	if len(committeeIDs) > 0 {
+		cursor, err := config.GetCollection("committees").Find(ctx, bson.M{"_id": bson.M{"$in": committeeIDs}})
+		if err != nil {
+			log.Printf("Error fetching invitation committees: %v", err)
+		} else {
+			var committees []models.Committee
+			if err := cursor.All(ctx, &committees); err != nil {
+				log.Printf("Error decoding invitation committees: %v", err)
+			}
+			for _, committee := range committees {
+				committeeNames[committee.ID] = committee.Name
+			}
+		}
+	}
+
+	results := make([]gin.H, 0, len(invitations))
+	for _, invitation := range invitations {
+		results = append(results, gin.H{
+			"invitation":    invitation,
+			"committeeName": committeeNames[invitation.CommitteeID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": results})
+}
+
+func AcceptInvitation(c *gin.Context) {
+	respondToInvitation(c, models.InvitationStatusAccepted)
+}
+
+func DeclineInvitation(c *gin.Context) {
+	respondToInvitation(c, models.InvitationStatusDeclined)
+}
+
+func respondToInvitation(c *gin.Context, status models.InvitationStatus) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	invitationID, err := primitive.ObjectIDFromHex(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid invitation ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := bson.M{
+		"_id":        invitationID,
+		"invitee_id": userID,
+		"status":     models.InvitationStatusPending,
+	}
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"status":       status,
+			"responded_at": now,
+		},
+	}
+
+	var invitation models.CommitteeInvitation
+	err = config.GetCollection("committee_invitations").FindOneAndUpdate(ctx, query, update).Decode(&invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "pending invitation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if status == models.InvitationStatusDeclined {
+		c.JSON(http.StatusOK, gin.H{"message": "invitation declined"})
+		return
+	}
+
+	field := "member_ids"
+	if invitation.Role == models.CommitteeRoleObserver {
+		field = "observer_ids"
+	}
+
+	result, err := config.GetCollection("committees").UpdateOne(ctx,
+		bson.M{"_id": invitation.CommitteeID, "archived": bson.M{"$ne": true}},
+		bson.M{
+			"$addToSet": bson.M{field: userID},
+			"$set":      bson.M{"updated_at": now},
+		},
+	)
+	if err != nil {
+		log.Printf("Error adding committee member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to join committee"})
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "committee not found or archived"})
+		return
+	}
+
+	services.AuditRoleChange(ctx, invitation.CommitteeID, userID, userID, models.CommitteeRoleNone, invitation.Role)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "invitation accepted",
+		"committeeId": invitation.CommitteeID,
+		"role":        invitation.Role,
+	})
+}
+
+func RemoveCommitteeMember(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid member ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	committee, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapManageMembers)
+	if !ok {
+		return
+	}
+
+	if memberID == committee.OwnerID || memberID == committee.ChairID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot remove the owner or chair"})
+		return
+	}
+
+	if !removeFromCommittee(c, ctx, committee, memberID) {
+		return
+	}
+	services.AuditRoleChange(ctx, committeeID, userID, memberID, services.RoleOf(committee, memberID), models.CommitteeRoleNone)
+	wsHub.RemoveUserFromRoom(memberID, models.CreateCommitteeRoomID(committeeID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "member removed"})
+}
+
+func LeaveCommittee(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	committee, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	if committee.OwnerID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the owner cannot leave the committee"})
+		return
+	}
+	if committee.ChairID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the chair must be reassigned before leaving"})
+		return
+	}
+
+	if !removeFromCommittee(c, ctx, committee, userID) {
+		return
+	}
+	services.AuditRoleChange(ctx, committeeID, userID, userID, services.RoleOf(committee, userID), models.CommitteeRoleNone)
+	wsHub.RemoveUserFromRoom(userID, models.CreateCommitteeRoomID(committeeID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "left committee"})
+}
+
+// removeFromCommittee drops the member from the roster. A secretary loses
+// the office with their seat, in the same update, so the committee is never
+// left with a secretary who is not a member.
+func removeFromCommittee(c *gin.Context, ctx context.Context, committee *models.Committee, memberID primitive.ObjectID) bool {
+	filter := bson.M{
+		"_id": committee.ID,
+		"$or": []bson.M{
+			{"member_ids": memberID},
+			{"observer_ids": memberID},
+		},
+	}
+	update := bson.M{
+		"$pull": bson.M{
+			"member_ids":   memberID,
+			"observer_ids": memberID,
+		},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	isSecretary := committee.SecretaryID != nil && *committee.SecretaryID == memberID
+	if isSecretary {
+		filter["secretary_id"] = memberID
+		update["$unset"] = bson.M{"secretary_id": ""}
+	}
+
+	result, err := config.GetCollection("committees").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error removing committee member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove member"})
+		return false
+	}
+
+	if result.MatchedCount == 0 {
+		if isSecretary {
+			c.JSON(http.StatusConflict, gin.H{"error": "the committee's officers changed; try again"})
+			return false
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "member not found in committee"})
+		return false
+	}
+
+	return true
+}
+
+func findInvitations(ctx context.Context, filter bson.M) ([]models.CommitteeInvitation, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := config.GetCollection("committee_invitations").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	invitations := []models.CommitteeInvitation{}
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetMinutes builds the minutes of one meeting, or of everything the
+// committee did between from and to, and serves them in the requested
+// format. Anything but json is sent as a download.
+func GetMinutes(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	format := models.MinutesFormat(c.DefaultQuery("format", string(models.MinutesFormatMarkdown)))
+	if !services.ValidMinutesFormat(format) {
+		respondServiceError(c, services.ErrInvalidMinutesFormat)
+		return
+	}
+
+	var query services.MinutesQuery
+	meetingIDStr := c.Param("meetingId")
+	if meetingIDStr == "" {
+		meetingIDStr = c.Query("meetingId")
+	}
+	if meetingIDStr != "" {
+		meetingID, err := primitive.ObjectIDFromHex(meetingIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid meeting ID"})
+			return
+		}
+		query.MeetingID = &meetingID
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseQueryDate(from, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+			return
+		}
+		query.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseQueryDate(to, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+			return
+		}
+		query.To = &t
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	minutes, err := services.GenerateMinutes(ctx, committeeID, userID, query)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	if format == models.MinutesFormatJSON {
+		c.JSON(http.StatusOK, gin.H{"minutes": minutes})
+		return
+	}
+
+	data, contentType, extension, err := services.RenderMinutes(minutes, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render minutes"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", services.MinutesFilename(minutes, extension)))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+type DraftMinutesRequest struct {
+	MeetingID string     `json:"meetingId,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+}
+
+type EditMinutesRequest struct {
+	Content string `json:"content" binding:"required"`
+	Note    string `json:"note"`
+	Version int    `json:"version" binding:"required"`
+}
+
+type MinutesCorrectionRequest struct {
+	Original    string `json:"original" binding:"required"`
+	Replacement string `json:"replacement"`
+	Reason      string `json:"reason"`
+}
+
+func DraftMinutes(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	var req DraftMinutesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := services.MinutesQuery{From: req.From, To: req.To}
+	if query.MeetingID, err = parseOptionalObjectID(req.MeetingID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid meeting ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	draft, err := services.DraftMinutes(ctx, committeeID, userID, query)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMinutes("minutes_drafted", draft)
+
+	c.JSON(http.StatusCreated, draft)
+}
+
+func GetMinutesDrafts(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	var statuses []models.MinutesStatus
+	if status := c.Query("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			statuses = append(statuses, models.MinutesStatus(s))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
+	drafts, err := services.ListMinutesDrafts(ctx, committeeID, statuses)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"minutes": drafts})
+}
+
+// GetMinutesDraft returns a draft with its history and corrections. With
+// ?format=md the current text is sent as a download instead.
+func GetMinutesDraft(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	draft, ok := loadMinutesDraft(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	if models.MinutesFormat(c.Query("format")) == models.MinutesFormatMarkdown {
+		filename := services.MinutesFilename(&draft.Record, "md")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(draft.Content))
+		return
+	}
+
+	corrections, err := services.ListCorrections(ctx, draft.ID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"minutes":     draft,
+		"corrections": corrections,
+	})
+}
+
+func EditMinutes(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req EditMinutesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	draft, ok := loadMinutesDraft(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	updated, err := services.EditMinutes(ctx, draft.ID, userID, req.Content, req.Note, req.Version)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMinutes("minutes_revised", updated)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func ProposeMinutesCorrection(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req MinutesCorrectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	draft, ok := loadMinutesDraft(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	correction, err := services.ProposeCorrection(ctx, draft.ID, userID, req.Original, req.Replacement, req.Reason)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMinutes("minutes_correction_proposed", draft)
+
+	c.JSON(http.StatusCreated, correction)
+}
+
+func AcceptMinutesCorrection(c *gin.Context) {
+	resolveMinutesCorrection(c, true)
+}
+
+func RejectMinutesCorrection(c *gin.Context) {
+	resolveMinutesCorrection(c, false)
+}
+
+func resolveMinutesCorrection(c *gin.Context, accept bool) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	correctionID, err := primitive.ObjectIDFromHex(c.Param("correctionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid correction ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	draft, ok := loadMinutesDraft(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	updated, correction, err := services.ResolveCorrection(ctx, draft.ID, correctionID, userID, accept)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	if accept {
+		wsHub.BroadcastMinutes("minutes_revised", updated)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"minutes":    updated,
+		"correction": correction,
+	})
+}
+
+// MoveToApproveMinutes puts an approve_minutes motion for the draft's current
+// version before the committee.
+func MoveToApproveMinutes(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	draft, ok := loadMinutesDraft(c, ctx, userID)
+	if !ok {
+		return
+	}
+
+	committee, err := services.GetCommittee(ctx, draft.CommitteeID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	motion, err := services.ProposeMotion(ctx, committee, userID, services.ProposeMotionInput{
+		Title:       "Approve the minutes: " + draft.Title,
+		Description: fmt.Sprintf("That the minutes of %s, as drafted in version %d, be approved.", draft.Title, draft.Version),
+		Kind:        models.MotionKindApproveMinutes,
+		MinutesID:   &draft.ID,
+	})
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMotionEvent("motion_proposed", motion, "")
+
+	c.JSON(http.StatusCreated, motion)
+}
+
+func loadMinutesDraft(c *gin.Context, ctx context.Context, userID primitive.ObjectID) (*models.MinutesDraft, bool) {
+	minutesID, err := primitive.ObjectIDFromHex(c.Param("minutesId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid minutes ID"})
+		return nil, false
+	}
+
+	draft, err := services.GetMinutesDraft(ctx, minutesID)
+	if err != nil {
+		respondServiceError(c, err)
+		return nil, false
+	}
+
+	if draft.CommitteeID.Hex() != c.Param("id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "minutes not found"})
+		return nil, false
+	}
+
+	if _, _, ok := authorizeCommittee(c, ctx, draft.CommitteeID, userID, services.CapViewCommittee); !ok {
+		return nil, false
+	}
+
+	return draft, true
+}
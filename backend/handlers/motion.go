@@ -1,15 +1,405 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ProposeMotionRequest struct {
+	Title       string               `json:"title" binding:"required"`
+	Description string               `json:"description"`
+	IsSpecial   bool                 `json:"isSpecial"`
+	Threshold   models.VoteThreshold `json:"threshold,omitempty"`
+	VoteMode    models.VoteMode      `json:"voteMode,omitempty"`
+	Kind        models.MotionKind    `json:"kind,omitempty"`
+	RefMotionID string               `json:"refMotionId,omitempty"`
+
+	ParentMotionID     string                `json:"parentMotionId,omitempty"`
+	Amendment          *models.AmendmentText `json:"amendment,omitempty"`
+	PostponeUntil      *time.Time            `json:"postponeUntil,omitempty"`
+	ReferToCommitteeID string                `json:"referToCommitteeId,omitempty"`
+	MinutesID          string                `json:"minutesId,omitempty"`
+	SuspendedRule      models.SuspendedRule  `json:"suspendedRule,omitempty"`
+}
+
+type UpdateMotionRequest struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+type ChangeMotionStatusRequest struct {
+	Status models.MotionStatus `json:"status" binding:"required"`
+}
+
+type RuleOnMotionRequest struct {
+	Decision    models.RulingDecision `json:"decision" binding:"required"`
+	Explanation string                `json:"explanation"`
+}
+
+func ProposeMotion(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	var req ProposeMotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input := services.ProposeMotionInput{
+		Title:       req.Title,
+		Description: req.Description,
+		IsSpecial:   req.IsSpecial,
+		Threshold:   req.Threshold,
+		VoteMode:    req.VoteMode,
+		Kind:        req.Kind,
+	}
+	input.Amendment = req.Amendment
+	input.PostponeUntil = req.PostponeUntil
+	input.SuspendedRule = req.SuspendedRule
+
+	if input.RefMotionID, err = parseOptionalObjectID(req.RefMotionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid referenced motion ID"})
+		return
+	}
+	if input.ParentMotionID, err = parseOptionalObjectID(req.ParentMotionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parent motion ID"})
+		return
+	}
+	if input.ReferToCommitteeID, err = parseOptionalObjectID(req.ReferToCommitteeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+	if input.MinutesID, err = parseOptionalObjectID(req.MinutesID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid minutes ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	committee, err := services.GetCommittee(ctx, committeeID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	motion, err := services.ProposeMotion(ctx, committee, userID, input)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMotionEvent("motion_proposed", motion, "")
+
+	c.JSON(http.StatusCreated, motion)
+}
+
+func GetMotions(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
+	var statuses []models.MotionStatus
+	if status := c.Query("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			statuses = append(statuses, models.MotionStatus(s))
+		}
+	}
+
+	motions, err := services.ListMotions(ctx, committeeID, statuses)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"motions": motions})
+}
 
 func GetMotion(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
 
+	c.JSON(http.StatusOK, motion)
 }
 
-func UpdateMotion() {
+func UpdateMotion(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
 
+	var req UpdateMotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Title == nil && req.Description == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapProposeMotion)
+	if !ok {
+		return
+	}
+
+	updated, err := services.UpdateMotionText(ctx, motion, userID, req.Title, req.Description)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMotionEvent("motion_updated", updated, "")
+
+	c.JSON(http.StatusOK, updated)
 }
 
-func DeleteMotion() {
+func DeleteMotion(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapProposeMotion)
+	if !ok {
+		return
+	}
+
+	updated, err := services.WithdrawMotion(ctx, motion, userID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMotionEvent("motion_withdrawn", updated, motion.Status)
+
+	c.JSON(http.StatusOK, gin.H{"message": "motion withdrawn"})
+}
+
+func SecondMotion(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	updated, err := services.SecondMotion(ctx, motion.ID, userID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMotionEvent("motion_seconded", updated, motion.Status)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func ChangeMotionStatus(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req ChangeMotionStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	updated, previous, err := services.ChangeMotionStatus(ctx, motion.ID, userID, req.Status)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMotionEvent("motion_status_changed", updated, previous)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func RuleOnMotion(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req RuleOnMotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	updated, err := services.RuleOnMotion(ctx, motion.ID, userID, req.Decision, req.Explanation)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMotionEvent("chair_ruling", updated, motion.Status)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func GetMotionKinds(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"kinds": services.MotionCatalog()})
+}
+
+func GetPendingQuestions(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	if motion.ParentMotionID != nil {
+		parent, err := services.GetMotion(ctx, *motion.ParentMotionID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		motion = parent
+	}
+
+	stack, err := services.PendingQuestions(ctx, motion)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pending": stack})
+}
+
+func parseOptionalObjectID(value string) (*primitive.ObjectID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := primitive.ObjectIDFromHex(value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func loadMotion(c *gin.Context, ctx context.Context, userID primitive.ObjectID, capability services.Capability) (*models.Motion, bool) {
+	motionID, err := primitive.ObjectIDFromHex(c.Param("motionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid motion ID"})
+		return nil, false
+	}
+
+	motion, err := services.GetMotion(ctx, motionID)
+	if err != nil {
+		respondServiceError(c, err)
+		return nil, false
+	}
+
+	committeeParam := c.Param("id")
+	if committeeParam == "" {
+		committeeParam = c.Param("comitteeId")
+	}
+	if committeeParam != "" && committeeParam != motion.CommitteeID.Hex() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "motion not found"})
+		return nil, false
+	}
+
+	if _, _, ok := authorizeCommittee(c, ctx, motion.CommitteeID, userID, capability); !ok {
+		return nil, false
+	}
 
+	return motion, true
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/zach-short/final-web-programming/config"
 	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -404,3 +405,107 @@ func (ns *NotificationService) CreateVoteNotification(motionID primitive.ObjectI
 	return err
 }
 
+func (ns *NotificationService) CreateCommitteeInvitationNotification(invitation models.CommitteeInvitation, committee models.Committee) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var invitee models.User
+	err := config.GetCollection("users").FindOne(ctx, bson.M{"_id": invitation.InviteeID}).Decode(&invitee)
+	if err != nil {
+		return err
+	}
+
+	settings := invitee.Settings
+	if settings == (models.UserSettings{}) {
+		settings = models.GetDefaultUserSettings()
+	}
+	if !settings.Notifications.CommitteeInvitations {
+		return nil
+	}
+
+	notification := models.Notification{
+		ID:         primitive.NewObjectID(),
+		Type:       "committee_invitation",
+		RelatedID:  &invitation.ID,
+		Title:      "Committee Invitation: " + committee.Name,
+		Message:    "You have been invited to join " + committee.Name + " as " + string(invitation.Role),
+		Urgency:    "medium",
+		CreatedBy:  invitation.InviterID,
+		Recipients: []primitive.ObjectID{invitation.InviteeID},
+		CreatedAt:  time.Now(),
+	}
+
+	href := "/invitations/" + invitation.ID.Hex()
+	notification.Href = &href
+
+	_, err = config.GetCollection("notifications").InsertOne(ctx, notification)
+	if err != nil {
+		return err
+	}
+
+	_, err = config.GetCollection("user_notifications").InsertOne(ctx, models.UserNotification{
+		ID:             primitive.NewObjectID(),
+		UserID:         invitation.InviteeID,
+		NotificationID: notification.ID,
+		Read:           false,
+		Dismissed:      false,
+		CreatedAt:      time.Now(),
+	})
+
+	return err
+}
+
+func (ns *NotificationService) CreateDeadlineReminder(motion models.Motion, reminder services.DeadlineReminder) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	closes := reminder.EndsAt.UTC().Format("Jan 2, 15:04 MST")
+	notificationType, urgency, href := "motion", "medium", "/motions/"+motion.ID.Hex()
+	var message string
+	switch reminder.Phase {
+	case models.DeadlineSeconding:
+		message = "This motion expires at " + closes + " unless it is seconded"
+	case models.DeadlineDebate:
+		message = "Debate closes at " + closes
+	case models.DeadlineVoting:
+		message = "Voting closes at " + closes + " and you have not voted yet"
+		notificationType, urgency, href = "vote", "high", "/voting/"+motion.ID.Hex()
+	}
+
+	notification := models.Notification{
+		ID:         primitive.NewObjectID(),
+		Type:       notificationType,
+		RelatedID:  &motion.ID,
+		Title:      "Reminder: " + motion.Title,
+		Message:    message,
+		Urgency:    urgency,
+		Href:       &href,
+		CreatedBy:  motion.MoverID,
+		Recipients: reminder.Recipients,
+		CreatedAt:  time.Now(),
+		ExpiresAt:  &reminder.EndsAt,
+	}
+
+	_, err := config.GetCollection("notifications").InsertOne(ctx, notification)
+	if err != nil {
+		return err
+	}
+
+	userNotifications := make([]interface{}, len(reminder.Recipients))
+	for i, memberID := range reminder.Recipients {
+		userNotifications[i] = models.UserNotification{
+			ID:             primitive.NewObjectID(),
+			UserID:         memberID,
+			NotificationID: notification.ID,
+			Read:           false,
+			Dismissed:      false,
+			CreatedAt:      time.Now(),
+		}
+	}
+
+	if len(userNotifications) > 0 {
+		_, err = config.GetCollection("user_notifications").InsertMany(ctx, userNotifications)
+	}
+
+	return err
+}
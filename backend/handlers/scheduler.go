@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	schedulerResync = 5 * time.Minute
+	schedulerRetry  = time.Minute
+)
+
+// deadlineScheduler wakes up for the next reminder or deadline of every motion
+// that has one. Its state is rebuilt from Mongo on start and every few
+// minutes after, so deadlines survive restarts and are picked up even when
+// they were set by another instance.
+type deadlineScheduler struct {
+	mutex sync.Mutex
+	next  map[primitive.ObjectID]time.Time
+	wake  chan struct{}
+}
+
+var deadlines = &deadlineScheduler{
+	next: make(map[primitive.ObjectID]time.Time),
+	wake: make(chan struct{}, 1),
+}
+
+func StartDeadlineScheduler() {
+	services.SetDeadlineListener(func(motionID primitive.ObjectID) {
+		go deadlines.reschedule(motionID)
+	})
+	go deadlines.run()
+}
+
+func (s *deadlineScheduler) run() {
+	s.resync()
+
+	resync := time.NewTicker(schedulerResync)
+	defer resync.Stop()
+
+	for {
+		timer := time.NewTimer(s.untilNext())
+		select {
+		case <-timer.C:
+			s.fireDue()
+		case <-s.wake:
+		case <-resync.C:
+			s.resync()
+		}
+		timer.Stop()
+	}
+}
+
+func (s *deadlineScheduler) untilNext() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	wait := schedulerResync
+	for _, at := range s.next {
+		if until := time.Until(at); until < wait {
+			wait = until
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+func (s *deadlineScheduler) set(motionID primitive.ObjectID, at time.Time, ok bool) {
+	s.mutex.Lock()
+	if ok {
+		s.next[motionID] = at
+	} else {
+		delete(s.next, motionID)
+	}
+	s.mutex.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *deadlineScheduler) resync() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	motions, err := services.PendingDeadlines(ctx)
+	if err != nil {
+		log.Printf("Error loading motion deadlines: %v", err)
+		return
+	}
+
+	settings := make(map[primitive.ObjectID]*models.CommitteeSettings)
+	next := make(map[primitive.ObjectID]time.Time, len(motions))
+	for i := range motions {
+		motion := &motions[i]
+		committeeSettings, ok := settings[motion.CommitteeID]
+		if !ok {
+			committeeSettings, err = services.GetSettings(ctx, motion.CommitteeID)
+			if err != nil {
+				log.Printf("Error loading committee settings: %v", err)
+				continue
+			}
+			settings[motion.CommitteeID] = committeeSettings
+		}
+		if at, ok := services.NextDeadlineEvent(motion, committeeSettings); ok {
+			next[motion.ID] = at
+		}
+	}
+
+	s.mutex.Lock()
+	s.next = next
+	s.mutex.Unlock()
+}
+
+func (s *deadlineScheduler) reschedule(motionID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, err := services.GetMotion(ctx, motionID)
+	if err != nil {
+		log.Printf("Error loading motion %s for scheduling: %v", motionID.Hex(), err)
+		return
+	}
+	settings, err := services.GetSettings(ctx, motion.CommitteeID)
+	if err != nil {
+		log.Printf("Error loading committee settings: %v", err)
+		return
+	}
+
+	at, ok := services.NextDeadlineEvent(motion, settings)
+	s.set(motionID, at, ok)
+}
+
+func (s *deadlineScheduler) fireDue() {
+	now := time.Now()
+
+	s.mutex.Lock()
+	var due []primitive.ObjectID
+	for motionID, at := range s.next {
+		if !at.After(now) {
+			due = append(due, motionID)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, motionID := range due {
+		s.process(motionID, now)
+	}
+}
+
+func (s *deadlineScheduler) process(motionID primitive.ObjectID, now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := services.ProcessDeadlines(ctx, motionID, now)
+	if err != nil {
+		log.Printf("Error processing deadlines for motion %s: %v", motionID.Hex(), err)
+		s.set(motionID, now.Add(schedulerRetry), true)
+		return
+	}
+
+	motion := result.Motion
+	for _, reminder := range result.Reminders {
+		if err := notificationService.CreateDeadlineReminder(*motion, reminder); err != nil {
+			log.Printf("Error sending deadline reminder: %v", err)
+		}
+	}
+	switch {
+	case result.Expired:
+		wsHub.BroadcastMotionEvent("motion_expired", motion, models.MotionStatusProposed)
+	case result.VotingClosed, result.EffectApplied:
+		broadcastVotingClosed(ctx, motion)
+	case result.DebateClosed:
+		wsHub.BroadcastMotionEvent("debate_closed", motion, "")
+	}
+
+	settings, err := services.GetSettings(ctx, motion.CommitteeID)
+	if err != nil {
+		log.Printf("Error loading committee settings: %v", err)
+		s.set(motionID, now.Add(schedulerRetry), true)
+		return
+	}
+
+	at, ok := services.NextDeadlineEvent(motion, settings)
+	if ok && !at.After(now) {
+		at = now.Add(schedulerRetry)
+	}
+	s.set(motionID, at, ok)
+}
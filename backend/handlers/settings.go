@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func GetCommitteeSettings(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
+	settings, err := services.GetSettings(ctx, committeeID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateCommitteeSettings answers 202 with the proposed motion when the
+// committee protects its settings and the change has to be voted on.
+func UpdateCommitteeSettings(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	var req models.SettingsChange
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	settings, motion, err := services.UpdateSettings(ctx, committeeID, userID, req)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	if motion != nil {
+		wsHub.BroadcastMotionEvent("motion_proposed", motion, "")
+		c.JSON(http.StatusAccepted, gin.H{"motion": motion})
+		return
+	}
+
+	wsHub.BroadcastSettings(settings)
+	if req.Quorum != nil {
+		wsHub.RefreshQuorum(committeeID)
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// GetControlPanel gathers what the chair needs to run the committee: its
+// settings, the meeting in progress with its quorum, and the business still
+// pending.
+func GetControlPanel(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	committeeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid committee ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	committee, role, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapPreside)
+	if !ok {
+		return
+	}
+
+	settings, err := services.GetSettings(ctx, committeeID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	meeting, err := services.ActiveMeeting(ctx, committeeID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	quorum, err := services.CommitteeQuorum(ctx, committeeID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	pending, err := services.ListMotions(ctx, committeeID, []models.MotionStatus{
+		models.MotionStatusProposed,
+		models.MotionStatusSeconded,
+		models.MotionStatusOpen,
+	})
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"committee":      committee,
+		"role":           role,
+		"capabilities":   services.Capabilities(role),
+		"settings":       settings,
+		"meeting":        meeting,
+		"quorum":         quorum,
+		"pendingMotions": pending,
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/zach-short/final-web-programming/config"
 	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
 	"github.com/zach-short/final-web-programming/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -329,6 +330,9 @@ func UpdateUserSettings(c *gin.Context) {
 		settings.Privacy.ShowFamilyName = req.Privacy.ShowFamilyName
 		settings.Privacy.ShowBio = req.Privacy.ShowBio
 		settings.Privacy.ShowPicture = req.Privacy.ShowPicture
+		if req.Privacy.ShowOnlineStatus != nil {
+			settings.Privacy.ShowOnlineStatus = req.Privacy.ShowOnlineStatus
+		}
 	}
 	if req.Notifications != nil {
 		settings.Notifications.EmailNotifications = req.Notifications.EmailNotifications
@@ -373,31 +377,23 @@ func getUserCommittees(ctx context.Context, userID primitive.ObjectID) ([]map[st
 		},
 	}
 
-	committeeItems, err := utils.FetchItems(query, "committees")
+	cursor, err := config.GetCollection("committees").Find(ctx, query)
 	if err != nil {
 		return committees, err
 	}
+	defer cursor.Close(ctx)
 
-	for _, item := range committeeItems {
-		role := "Observer"
-		if item["owner_id"] == userID {
-			role = "Owner"
-		} else if item["chair_id"] == userID {
-			role = "Chair"
-		} else if memberIDs, ok := item["member_ids"].(primitive.A); ok {
-			for _, memberID := range memberIDs {
-				if memberID == userID {
-					role = "Member"
-					break
-				}
-			}
-		}
+	var committeeItems []models.Committee
+	if err := cursor.All(ctx, &committeeItems); err != nil {
+		return committees, err
+	}
 
+	for _, item := range committeeItems {
 		committee := map[string]any{
-			"id":   item["_id"],
-			"name": item["name"],
-			"type": item["type"],
-			"role": role,
+			"id":   item.ID,
+			"name": item.Name,
+			"type": item.Type,
+			"role": services.RoleLabel(services.RoleOf(&item, userID)),
 		}
 		committees = append(committees, committee)
 	}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CastVoteRequest struct {
+	Vote models.VoteResult `json:"vote" binding:"required"`
+}
+
+type SetVotingDeadlineRequest struct {
+	VotingEndsAt time.Time `json:"votingEndsAt" binding:"required"`
+}
+
+func CastVote(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req CastVoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	motion, vote, err := services.CastVote(ctx, motion.ID, userID, req.Vote)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	if vote == nil {
+		ballotsCast, err := services.BallotsCast(ctx, motion.ID)
+		if err != nil {
+			log.Printf("Error counting ballots: %v", err)
+		}
+
+		wsHub.BroadcastSecretBallot(motion, ballotsCast)
+
+		c.JSON(http.StatusOK, gin.H{
+			"recorded":    true,
+			"ballotsCast": ballotsCast,
+		})
+		return
+	}
+
+	tally, err := services.CurrentTally(ctx, motion.ID)
+	if err != nil {
+		log.Printf("Error computing tally: %v", err)
+	}
+
+	wsHub.BroadcastVote(motion, vote, tally)
+
+	c.JSON(http.StatusOK, gin.H{
+		"vote":  vote,
+		"tally": tally,
+	})
+}
+
+func GetMotionVotes(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	if services.IsSecretBallot(motion) {
+		ballotsCast, err := services.BallotsCast(ctx, motion.ID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"voteMode":    motion.VoteMode,
+			"ballotsCast": ballotsCast,
+			"tally":       motion.Tally,
+		})
+		return
+	}
+
+	votes, err := services.GetVotes(ctx, motion.ID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"voteMode": models.VoteModeRollCall,
+		"votes":    votes,
+		"tally":    services.ComputeTally(votes),
+	})
+}
+
+func CloseVoting(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	updated, err := services.CloseVoting(ctx, motion.ID, userID)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	broadcastVotingClosed(ctx, updated)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func SetVotingDeadline(c *gin.Context) {
+	userIDStr := c.GetString("userID")
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req SetVotingDeadlineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	motion, ok := loadMotion(c, ctx, userID, services.CapViewCommittee)
+	if !ok {
+		return
+	}
+
+	updated, err := services.SetVotingDeadline(ctx, motion.ID, userID, req.VotingEndsAt)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	wsHub.BroadcastMotionEvent("motion_updated", updated, "")
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func broadcastVotingClosed(ctx context.Context, motion *models.Motion) {
+	effects, err := services.Effects(ctx, motion)
+	if err != nil {
+		log.Printf("Error loading motion effects: %v", err)
+	}
+	wsHub.BroadcastVotingClosed(motion, effects)
+}
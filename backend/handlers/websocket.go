@@ -11,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/zach-short/final-web-programming/config"
 	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
 	"github.com/zach-short/final-web-programming/utils"
 	websocketPkg "github.com/zach-short/final-web-programming/websocket"
 	"go.mongodb.org/mongo-driver/bson"
@@ -20,8 +21,27 @@ import (
 
 var wsHub *websocketPkg.Hub
 
-func init() {
-	wsHub = websocketPkg.NewHub()
+// StartWebSocketHub starts this instance's hub. With REDIS_URL set, room
+// events are shared with the other instances through Redis; otherwise the
+// hub only serves this instance.
+func StartWebSocketHub() {
+	var broker websocketPkg.Broker = websocketPkg.NewMemoryBroker()
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		redisBroker, err := websocketPkg.NewRedisBroker(ctx, url)
+		cancel()
+		if err != nil {
+			log.Fatalf("Failed to connect to the WebSocket broker: %v", err)
+		}
+		broker = redisBroker
+	}
+
+	hub, err := websocketPkg.NewHub(broker)
+	if err != nil {
+		log.Fatalf("Failed to start the WebSocket hub: %v", err)
+	}
+	wsHub = hub
+	services.SetPresenceSource(wsHub.ConnectedUsers)
 	go wsHub.Run()
 }
 
@@ -54,6 +74,39 @@ func HandleWebSocket(c *gin.Context) {
 	go client.ReadPump()
 }
 
+var roomErrorStatus = map[models.WSErrorCode]int{
+	models.WSErrorNotFound:   http.StatusNotFound,
+	models.WSErrorPermission: http.StatusForbidden,
+	models.WSErrorValidation: http.StatusBadRequest,
+}
+
+// GetRoomPresence lists who is connected to a room and who is typing, for
+// clients that have just joined and missed the presence events.
+func GetRoomPresence(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	roomID := c.Param("roomId")
+	if err := websocketPkg.AuthorizeRoom(userID, roomID, services.CapViewCommittee); err != nil {
+		status, ok := roomErrorStatus[websocketPkg.ErrorCode(err)]
+		if !ok {
+			log.Printf("Error authorizing room %s: %v", roomID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roomId": roomID,
+		"users":  wsHub.RoomPresence(roomID),
+	})
+}
+
 func StartDMConversation(c *gin.Context) {
 	userIDStr := c.MustGet("userID").(string)
 	userID, err := primitive.ObjectIDFromHex(userIDStr)
@@ -345,6 +398,13 @@ func StartCommitteeChat(c *gin.Context) {
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, _, ok := authorizeCommittee(c, ctx, committeeID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
 	roomID := models.CreateCommitteeRoomID(committeeID)
 
 	room := models.Room{
@@ -363,12 +423,12 @@ func StartCommitteeChat(c *gin.Context) {
 }
 
 func GetCommitteeHistory(c *gin.Context) {
-	/* userIDStr := c.MustGet("userID").(string) */
-	/* userID, err := primitive.ObjectIDFromHex(userIDStr) */
-	/* if err != nil { */
-	/* 	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"}) */
-	/* 	return */
-	/* } */
+	userIDStr := c.MustGet("userID").(string)
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 
 	committeeID := c.Param("id")
 	committeeOID, err := primitive.ObjectIDFromHex(committeeID)
@@ -377,12 +437,16 @@ func GetCommitteeHistory(c *gin.Context) {
 		return
 	}
 
-	roomID := models.CreateCommitteeRoomID(committeeOID)
-
 	collection := config.DB.Database(os.Getenv("DATABASE_NAME")).Collection("messages")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if _, _, ok := authorizeCommittee(c, ctx, committeeOID, userID, services.CapViewCommittee); !ok {
+		return
+	}
+
+	roomID := models.CreateCommitteeRoomID(committeeOID)
+
 	filter := bson.M{"roomId": roomID}
 	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}).SetLimit(200)
 
@@ -680,6 +744,8 @@ func EditMessage(c *gin.Context) {
 		return
 	}
 
+	services.AuditMessageEdit(ctx, &message, userID, req.Content)
+
 	wsMessage := models.WSMessage{
 		Action: "message_edited",
 		Type:   models.TypeSystem,
@@ -741,6 +807,8 @@ func DeleteMessage(c *gin.Context) {
 		return
 	}
 
+	services.AuditMessageDelete(ctx, &message, userID)
+
 	wsMessage := models.WSMessage{
 		Action: "message_deleted",
 		Type:   models.TypeSystem,
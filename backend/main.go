@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/handlers"
 	"github.com/zach-short/final-web-programming/routes"
+	"github.com/zach-short/final-web-programming/services"
 )
 
 func main() {
@@ -43,6 +47,27 @@ func main() {
 
 	config.ConnectDB()
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := services.EnsureAuditIndexes(ctx); err != nil {
+		log.Printf("Error creating audit log indexes: %v", err)
+	}
+	if err := services.EnsureVoteIndexes(ctx); err != nil {
+		log.Printf("Error creating vote indexes: %v", err)
+	}
+	if err := services.EnsureBallotIndexes(ctx); err != nil {
+		log.Printf("Error creating ballot indexes: %v", err)
+	}
+	if err := services.EnsureMeetingIndexes(ctx); err != nil {
+		log.Printf("Error creating meeting indexes: %v", err)
+	}
+	if err := services.EnsureMinutesIndexes(ctx); err != nil {
+		log.Printf("Error creating minutes indexes: %v", err)
+	}
+	cancel()
+
+	handlers.StartWebSocketHub()
+	handlers.StartDeadlineScheduler()
+
 	routes.SetupRoutes(r)
 
 	port := os.Getenv("PORT")
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditAction string
+
+const (
+	AuditMotionProposed     AuditAction = "motion_proposed"
+	AuditMotionEdited       AuditAction = "motion_edited"
+	AuditMotionWithdrawn    AuditAction = "motion_withdrawn"
+	AuditMotionStatus       AuditAction = "motion_status_changed"
+	AuditVoteCast           AuditAction = "vote_cast"
+	AuditBallotCast         AuditAction = "ballot_cast"
+	AuditSettingsChanged    AuditAction = "settings_changed"
+	AuditMinutesDrafted     AuditAction = "minutes_drafted"
+	AuditMinutesRevised     AuditAction = "minutes_revised"
+	AuditMinutesApproved    AuditAction = "minutes_approved"
+	AuditCorrectionProposed AuditAction = "correction_proposed"
+	AuditCorrectionResolved AuditAction = "correction_resolved"
+	AuditRoleChanged        AuditAction = "role_changed"
+	AuditMessageEdited      AuditAction = "message_edited"
+	AuditMessageDeleted     AuditAction = "message_deleted"
+)
+
+// AuditEntry is one link in an append-only chain. Hash covers every other
+// field, including the previous entry's hash, so altering or removing any
+// entry breaks every link after it.
+type AuditEntry struct {
+	ID        primitive.ObjectID  `bson:"_id" json:"id"`
+	Chain     string              `bson:"chain" json:"chain"`
+	Sequence  int64               `bson:"sequence" json:"sequence"`
+	Action    AuditAction         `bson:"action" json:"action"`
+	ActorID   *primitive.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	SubjectID *primitive.ObjectID `bson:"subject_id,omitempty" json:"subject_id,omitempty"`
+	Details   map[string]string   `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+	PrevHash  string              `bson:"prev_hash" json:"prev_hash"`
+	Hash      string              `bson:"hash" json:"hash"`
+}
+
+// AuditHead records the newest entry of a chain apart from the chain itself,
+// so entries removed from the end are noticed even though what remains still
+// links up.
+type AuditHead struct {
+	Chain     string    `bson:"_id" json:"chain"`
+	Sequence  int64     `bson:"sequence" json:"sequence"`
+	Hash      string    `bson:"hash" json:"hash"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// AuditBreak is the first entry that does not fit the chain.
+type AuditBreak struct {
+	Sequence int64              `json:"sequence"`
+	EntryID  primitive.ObjectID `json:"entry_id,omitempty"`
+	Reason   string             `json:"reason"`
+}
+
+type AuditVerification struct {
+	Chain      string      `json:"chain"`
+	Entries    int64       `json:"entries"`
+	Valid      bool        `json:"valid"`
+	HeadHash   string      `json:"head_hash,omitempty"`
+	FirstBreak *AuditBreak `json:"first_break,omitempty"`
+	VerifiedAt time.Time   `json:"verified_at"`
+}
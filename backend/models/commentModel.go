@@ -14,18 +14,60 @@ const (
 	VoteAbstain VoteResult = "abstain"
 )
 
+type Stance string
+
+const (
+	StancePro     Stance = "pro"
+	StanceCon     Stance = "con"
+	StanceNeutral Stance = "neutral"
+)
+
 type Vote struct {
 	ID        primitive.ObjectID `bson:"_id" json:"_id"`
 	MotionID  primitive.ObjectID `bson:"motion_id" json:"motion_id"`
 	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
 	Result    VoteResult         `bson:"result" json:"result"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
-type Comment struct {
+// VoteParticipation records that a member voted on a secret ballot without
+// recording how. The ballot itself only increments the motion's BallotBox.
+type VoteParticipation struct {
 	ID        primitive.ObjectID `bson:"_id" json:"_id"`
 	MotionID  primitive.ObjectID `bson:"motion_id" json:"motion_id"`
 	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Content   string             `bson:"content" json:"content"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 }
+
+type BallotBox struct {
+	MotionID    primitive.ObjectID `bson:"_id" json:"motion_id"`
+	Ayes        int                `bson:"ayes" json:"ayes"`
+	Nays        int                `bson:"nays" json:"nays"`
+	Abstentions int                `bson:"abstentions" json:"abstentions"`
+}
+
+type Comment struct {
+	ID              primitive.ObjectID  `bson:"_id" json:"_id"`
+	MotionID        primitive.ObjectID  `bson:"motion_id" json:"motion_id"`
+	UserID          primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	ParentID        *primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
+	Stance          Stance              `bson:"stance" json:"stance"`
+	Content         string              `bson:"content" json:"content"`
+	IsEdited        bool                `bson:"is_edited" json:"is_edited"`
+	OriginalContent string              `bson:"original_content,omitempty" json:"original_content,omitempty"`
+	EditedAt        *time.Time          `bson:"edited_at,omitempty" json:"edited_at,omitempty"`
+	IsDeleted       bool                `bson:"is_deleted" json:"is_deleted"`
+	CreatedAt       time.Time           `bson:"created_at" json:"created_at"`
+}
+
+type CommentThread struct {
+	Comment
+	Replies []*CommentThread `json:"replies"`
+}
+
+type StanceCounts struct {
+	Pro     int `json:"pro"`
+	Con     int `json:"con"`
+	Neutral int `json:"neutral"`
+}
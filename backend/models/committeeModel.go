@@ -1,6 +1,10 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Committee struct {
 	Name        string               `bson:"name" json:"name"`
@@ -8,6 +12,59 @@ type Committee struct {
 	Type        string               `bson:"type" json:"type"`
 	OwnerID     primitive.ObjectID   `bson:"owner_id" json:"owner_id"`
 	ChairID     primitive.ObjectID   `bson:"chair_id" json:"chair_id"`
+	SecretaryID *primitive.ObjectID  `bson:"secretary_id,omitempty" json:"secretary_id,omitempty"`
 	MemberIDs   []primitive.ObjectID `bson:"member_ids" json:"member_ids"`
 	ObserverIDs []primitive.ObjectID `bson:"observer_ids" json:"observer_id"`
+	Archived    bool                 `bson:"archived" json:"archived"`
+	ArchivedAt  *time.Time           `bson:"archived_at,omitempty" json:"archived_at,omitempty"`
+	CreatedAt   time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+type CreateCommitteeRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Type    string `json:"type"`
+	ChairID string `json:"chairId,omitempty"`
+}
+
+type UpdateCommitteeRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Type        *string `json:"type,omitempty"`
+	ChairID     *string `json:"chairId,omitempty"`
+	SecretaryID *string `json:"secretaryId,omitempty"`
+}
+
+type CommitteeRole string
+
+const (
+	CommitteeRoleNone      CommitteeRole = ""
+	CommitteeRoleOwner     CommitteeRole = "owner"
+	CommitteeRoleChair     CommitteeRole = "chair"
+	CommitteeRoleSecretary CommitteeRole = "secretary"
+	CommitteeRoleMember    CommitteeRole = "member"
+	CommitteeRoleObserver  CommitteeRole = "observer"
+)
+
+type InvitationStatus string
+
+const (
+	InvitationStatusPending  InvitationStatus = "pending"
+	InvitationStatusAccepted InvitationStatus = "accepted"
+	InvitationStatusDeclined InvitationStatus = "declined"
+)
+
+type CommitteeInvitation struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	CommitteeID primitive.ObjectID `bson:"committee_id" json:"committee_id"`
+	InviterID   primitive.ObjectID `bson:"inviter_id" json:"inviter_id"`
+	InviteeID   primitive.ObjectID `bson:"invitee_id" json:"invitee_id"`
+	Role        CommitteeRole      `bson:"role" json:"role"`
+	Status      InvitationStatus   `bson:"status" json:"status"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	RespondedAt *time.Time         `bson:"responded_at,omitempty" json:"responded_at,omitempty"`
+}
+
+type InviteMemberRequest struct {
+	UserID string        `json:"userId" binding:"required"`
+	Role   CommitteeRole `json:"role"`
 }
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MeetingStatus string
+
+const (
+	MeetingStatusScheduled  MeetingStatus = "scheduled"
+	MeetingStatusInProgress MeetingStatus = "in_progress"
+	MeetingStatusRecessed   MeetingStatus = "recessed"
+	MeetingStatusAdjourned  MeetingStatus = "adjourned"
+)
+
+type AgendaItemStatus string
+
+const (
+	AgendaItemPending AgendaItemStatus = "pending"
+	AgendaItemCurrent AgendaItemStatus = "current"
+	AgendaItemDone    AgendaItemStatus = "done"
+)
+
+type AgendaItem struct {
+	ID          primitive.ObjectID  `bson:"_id" json:"_id"`
+	Title       string              `bson:"title" json:"title"`
+	Description string              `bson:"description,omitempty" json:"description,omitempty"`
+	MotionID    *primitive.ObjectID `bson:"motion_id,omitempty" json:"motion_id,omitempty"`
+	Status      AgendaItemStatus    `bson:"status" json:"status"`
+	StartedAt   *time.Time          `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	CompletedAt *time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+type AttendanceRecord struct {
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	CheckedInAt  time.Time          `bson:"checked_in_at" json:"checked_in_at"`
+	CheckedOutAt *time.Time         `bson:"checked_out_at,omitempty" json:"checked_out_at,omitempty"`
+}
+
+type Meeting struct {
+	ID           primitive.ObjectID   `bson:"_id" json:"_id"`
+	CommitteeID  primitive.ObjectID   `bson:"committee_id" json:"committee_id"`
+	Title        string               `bson:"title" json:"title"`
+	Status       MeetingStatus        `bson:"status" json:"status"`
+	Agenda       []AgendaItem         `bson:"agenda" json:"agenda"`
+	CurrentItem  int                  `bson:"current_item" json:"current_item"`
+	Motions      []primitive.ObjectID `bson:"motions" json:"motions"`
+	Attendance   []AttendanceRecord   `bson:"attendance" json:"attendance"`
+	ScheduledFor time.Time            `bson:"scheduled_for" json:"scheduled_for"`
+	StartTime    *time.Time           `bson:"start_time,omitempty" json:"start_time,omitempty"`
+	EndTime      *time.Time           `bson:"end_time,omitempty" json:"end_time,omitempty"`
+	Quorum       *QuorumAnnouncement  `bson:"quorum,omitempty" json:"quorum,omitempty"`
+	QuorumSeq    int64                `bson:"quorum_seq" json:"-"`
+	CreatedBy    primitive.ObjectID   `bson:"created_by" json:"created_by"`
+	CreatedAt    time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+type AgendaItemRequest struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	MotionID    string `json:"motionId,omitempty"`
+}
+
+type ScheduleMeetingRequest struct {
+	Title        string              `json:"title" binding:"required"`
+	ScheduledFor time.Time           `json:"scheduledFor" binding:"required"`
+	Agenda       []AgendaItemRequest `json:"agenda"`
+}
+
+// QuorumAnnouncement is the quorum count last announced to a meeting's room.
+type QuorumAnnouncement struct {
+	PresentCount int  `bson:"present_count" json:"present_count"`
+	Required     int  `bson:"required" json:"required"`
+	HasQuorum    bool `bson:"has_quorum" json:"has_quorum"`
+}
+
+// QuorumStatus is the live count for a meeting: voting members who have
+// checked in and are still connected to the committee room. Present names
+// only those who share their online status; PresentCount counts everyone.
+type QuorumStatus struct {
+	CommitteeID  primitive.ObjectID   `json:"committee_id"`
+	MeetingID    primitive.ObjectID   `json:"meeting_id"`
+	Present      []primitive.ObjectID `json:"present"`
+	PresentCount int                  `json:"present_count"`
+	Required     int                  `json:"required"`
+	Membership   int                  `json:"membership"`
+	HasQuorum    bool                 `json:"has_quorum"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -37,10 +38,15 @@ type Message struct {
 }
 
 
+// WSMessage is a frame sent by the server. Frames broadcast to a room carry
+// the room's ID and their place in its event stream.
 type WSMessage struct {
-	Action  string      `json:"action"`
-	Type    MessageType `json:"type"`
-	Payload any         `json:"payload"`
+	Action    string      `json:"action"`
+	Type      MessageType `json:"type"`
+	RequestID string      `json:"requestId,omitempty"`
+	RoomID    string      `json:"roomId,omitempty"`
+	Seq       uint64      `json:"seq,omitempty"`
+	Payload   any         `json:"payload"`
 }
 
 type RoomType string
@@ -71,6 +77,23 @@ func CreateDMRoomID(userID1, userID2 primitive.ObjectID) string {
 	return "dm_" + userID2.Hex() + "_" + userID1.Hex()
 }
 
+// ParseDMRoomID returns the two participants encoded in a DM room ID.
+func ParseDMRoomID(roomID string) (primitive.ObjectID, primitive.ObjectID, bool) {
+	parts := strings.Split(roomID, "_")
+	if len(parts) != 3 || parts[0] != "dm" {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	first, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	second, err := primitive.ObjectIDFromHex(parts[2])
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return first, second, true
+}
+
 func CreateGroupRoomID(groupID primitive.ObjectID) string {
 	return "group_" + groupID.Hex()
 }
@@ -78,3 +101,14 @@ func CreateGroupRoomID(groupID primitive.ObjectID) string {
 func CreateCommitteeRoomID(committeeID primitive.ObjectID) string {
 	return "committee_" + committeeID.Hex()
 }
+
+func ParseCommitteeRoomID(roomID string) (primitive.ObjectID, bool) {
+	if !strings.HasPrefix(roomID, "committee_") {
+		return primitive.NilObjectID, false
+	}
+	committeeID, err := primitive.ObjectIDFromHex(strings.TrimPrefix(roomID, "committee_"))
+	if err != nil {
+		return primitive.NilObjectID, false
+	}
+	return committeeID, true
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MinutesFormat string
+
+const (
+	MinutesFormatJSON     MinutesFormat = "json"
+	MinutesFormatMarkdown MinutesFormat = "md"
+	MinutesFormatHTML     MinutesFormat = "html"
+	MinutesFormatPDF      MinutesFormat = "pdf"
+)
+
+type MinutesPerson struct {
+	ID   primitive.ObjectID `bson:"id" json:"id"`
+	Name string             `bson:"name" json:"name"`
+}
+
+type MinutesAttendee struct {
+	MinutesPerson `bson:",inline"`
+	Role          CommitteeRole `bson:"role" json:"role"`
+	CheckedInAt   *time.Time    `bson:"checked_in_at,omitempty" json:"checked_in_at,omitempty"`
+	CheckedOutAt  *time.Time    `bson:"checked_out_at,omitempty" json:"checked_out_at,omitempty"`
+}
+
+type MinutesPoint struct {
+	Speaker string    `bson:"speaker" json:"speaker"`
+	Stance  Stance    `bson:"stance" json:"stance"`
+	Content string    `bson:"content" json:"content"`
+	At      time.Time `bson:"at" json:"at"`
+}
+
+type MinutesDebate struct {
+	StanceCounts StanceCounts   `bson:"stance_counts" json:"stance_counts"`
+	Comments     int            `bson:"comments" json:"comments"`
+	Messages     int            `bson:"messages" json:"messages"`
+	Points       []MinutesPoint `bson:"points" json:"points"`
+}
+
+type MinutesVote struct {
+	Name   string     `bson:"name" json:"name"`
+	Result VoteResult `bson:"result" json:"result"`
+}
+
+type MinutesMotion struct {
+	ID          primitive.ObjectID `bson:"id" json:"id"`
+	Kind        MotionKind         `bson:"kind" json:"kind"`
+	Title       string             `bson:"title" json:"title"`
+	Description string             `bson:"description" json:"description"`
+	Status      MotionStatus       `bson:"status" json:"status"`
+	Mover       MinutesPerson      `bson:"mover" json:"mover"`
+	Seconder    *MinutesPerson     `bson:"seconder,omitempty" json:"seconder,omitempty"`
+	ProposedAt  time.Time          `bson:"proposed_at" json:"proposed_at"`
+	Debate      MinutesDebate      `bson:"debate" json:"debate"`
+	Tally       *VoteTally         `bson:"tally,omitempty" json:"tally,omitempty"`
+	VoteMode    VoteMode           `bson:"vote_mode" json:"vote_mode"`
+	RollCall    []MinutesVote      `bson:"roll_call,omitempty" json:"roll_call,omitempty"`
+	Ruling      *ChairRuling       `bson:"ruling,omitempty" json:"ruling,omitempty"`
+	Summary     *DecisionSummary   `bson:"summary,omitempty" json:"summary,omitempty"`
+}
+
+type MinutesMessage struct {
+	Sender  string    `bson:"sender" json:"sender"`
+	Content string    `bson:"content" json:"content"`
+	At      time.Time `bson:"at" json:"at"`
+}
+
+// Minutes is the formal record of a meeting, or of a committee's business
+// over a date range when it works asynchronously.
+type Minutes struct {
+	CommitteeID   primitive.ObjectID  `bson:"committee_id" json:"committee_id"`
+	CommitteeName string              `bson:"committee_name" json:"committee_name"`
+	MeetingID     *primitive.ObjectID `bson:"meeting_id,omitempty" json:"meeting_id,omitempty"`
+	Title         string              `bson:"title" json:"title"`
+	From          time.Time           `bson:"from" json:"from"`
+	To            time.Time           `bson:"to" json:"to"`
+	CalledToOrder *time.Time          `bson:"called_to_order,omitempty" json:"called_to_order,omitempty"`
+	Adjourned     *time.Time          `bson:"adjourned,omitempty" json:"adjourned,omitempty"`
+	Chair         MinutesPerson       `bson:"chair" json:"chair"`
+	Attendance    []MinutesAttendee   `bson:"attendance" json:"attendance"`
+	Absent        []MinutesPerson     `bson:"absent" json:"absent"`
+	Agenda        []AgendaItem        `bson:"agenda,omitempty" json:"agenda,omitempty"`
+	Motions       []MinutesMotion     `bson:"motions" json:"motions"`
+	Discussion    []MinutesMessage    `bson:"discussion" json:"discussion"`
+	GeneratedAt   time.Time           `bson:"generated_at" json:"generated_at"`
+}
+
+type MinutesStatus string
+
+const (
+	MinutesStatusDraft MinutesStatus = "draft"
+	// MinutesStatusPendingApproval locks a draft while a motion to approve it
+	// is before the committee.
+	MinutesStatusPendingApproval MinutesStatus = "pending_approval"
+	MinutesStatusApproved        MinutesStatus = "approved"
+)
+
+// MinutesRevision is one saved version of a draft. Version 1 is the generated
+// text; every edit or accepted correction adds the next.
+type MinutesRevision struct {
+	Version      int                 `bson:"version" json:"version"`
+	Content      string              `bson:"content" json:"content"`
+	Diff         []DiffOp            `bson:"diff,omitempty" json:"diff,omitempty"`
+	Note         string              `bson:"note,omitempty" json:"note,omitempty"`
+	CorrectionID *primitive.ObjectID `bson:"correction_id,omitempty" json:"correction_id,omitempty"`
+	EditedBy     primitive.ObjectID  `bson:"edited_by" json:"edited_by"`
+	EditedAt     time.Time           `bson:"edited_at" json:"edited_at"`
+}
+
+// MinutesDraft is the editable text of a set of minutes and its history.
+// Once an approve_minutes motion passes it is frozen at the approved version.
+type MinutesDraft struct {
+	ID               primitive.ObjectID  `bson:"_id" json:"id"`
+	CommitteeID      primitive.ObjectID  `bson:"committee_id" json:"committee_id"`
+	MeetingID        *primitive.ObjectID `bson:"meeting_id,omitempty" json:"meeting_id,omitempty"`
+	Title            string              `bson:"title" json:"title"`
+	From             time.Time           `bson:"from" json:"from"`
+	To               time.Time           `bson:"to" json:"to"`
+	Status           MinutesStatus       `bson:"status" json:"status"`
+	Version          int                 `bson:"version" json:"version"`
+	Content          string              `bson:"content" json:"content"`
+	Record           Minutes             `bson:"record" json:"record"`
+	History          []MinutesRevision   `bson:"history" json:"history"`
+	ApprovalMotionID *primitive.ObjectID `bson:"approval_motion_id,omitempty" json:"approval_motion_id,omitempty"`
+	ApprovedAt       *time.Time          `bson:"approved_at,omitempty" json:"approved_at,omitempty"`
+	CreatedBy        primitive.ObjectID  `bson:"created_by" json:"created_by"`
+	CreatedAt        time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+type CorrectionStatus string
+
+const (
+	CorrectionPending  CorrectionStatus = "pending"
+	CorrectionAccepted CorrectionStatus = "accepted"
+	CorrectionRejected CorrectionStatus = "rejected"
+)
+
+// MinutesCorrection is a member's proposed change to a draft: replace the
+// passage Original with Replacement.
+type MinutesCorrection struct {
+	ID          primitive.ObjectID  `bson:"_id" json:"id"`
+	MinutesID   primitive.ObjectID  `bson:"minutes_id" json:"minutes_id"`
+	ProposedBy  primitive.ObjectID  `bson:"proposed_by" json:"proposed_by"`
+	Version     int                 `bson:"version" json:"version"`
+	Original    string              `bson:"original" json:"original"`
+	Replacement string              `bson:"replacement" json:"replacement"`
+	Reason      string              `bson:"reason,omitempty" json:"reason,omitempty"`
+	Status      CorrectionStatus    `bson:"status" json:"status"`
+	ResolvedBy  *primitive.ObjectID `bson:"resolved_by,omitempty" json:"resolved_by,omitempty"`
+	ResolvedAt  *time.Time          `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
+}
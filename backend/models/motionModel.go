@@ -6,38 +6,224 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type Meeting struct {
-	ID          primitive.ObjectID   `bson:"_id" json:"_id"`
-	CommitteeID primitive.ObjectID   `bson:"committee_id" json:"committee_id"`
-	Motions     []primitive.ObjectID `bson:"motions" json:"motions"`
-	StartTime   time.Time            `bson:"start_time" json:"start_time"`
-	EndTime     time.Time            `bson:"end_time" json:"end_time"`
-}
-
 type MotionStatus string
 
 const (
-	MotionStatusProposed MotionStatus = "proposed"
-	MotionStatusSeconded MotionStatus = "seconded"
-	MotionStatusOpen     MotionStatus = "open"
-	MotionStatusPassed   MotionStatus = "passed"
-	MotionStatusFailed   MotionStatus = "failed"
-	MotionStatusTabled   MotionStatus = "tabled"
+	MotionStatusProposed  MotionStatus = "proposed"
+	MotionStatusSeconded  MotionStatus = "seconded"
+	MotionStatusOpen      MotionStatus = "open"
+	MotionStatusPassed    MotionStatus = "passed"
+	MotionStatusFailed    MotionStatus = "failed"
+	MotionStatusTabled    MotionStatus = "tabled"
+	MotionStatusPostponed MotionStatus = "postponed"
+	MotionStatusReferred  MotionStatus = "referred"
+	MotionStatusRuled     MotionStatus = "ruled"
+	MotionStatusExpired   MotionStatus = "expired"
+	MotionStatusWithdrawn MotionStatus = "withdrawn"
+)
+
+// DeadlinePhase names the windows an asynchronous motion moves through.
+type DeadlinePhase string
+
+const (
+	DeadlineSeconding DeadlinePhase = "seconding"
+	DeadlineDebate    DeadlinePhase = "debate"
+	DeadlineVoting    DeadlinePhase = "voting"
+)
+
+type VoteThreshold string
+
+const (
+	ThresholdMajority         VoteThreshold = "majority"
+	ThresholdTwoThirds        VoteThreshold = "two_thirds"
+	ThresholdMajorityPresent  VoteThreshold = "majority_present"
+	ThresholdMajorityEntire   VoteThreshold = "majority_entire_membership"
+	ThresholdUnanimousConsent VoteThreshold = "unanimous"
+)
+
+type VoteMode string
+
+const (
+	VoteModeRollCall VoteMode = "roll_call"
+	VoteModeSecret   VoteMode = "secret"
+)
+
+type MotionKind string
+
+const (
+	MotionKindMain           MotionKind = "main"
+	MotionKindReconsider     MotionKind = "reconsider"
+	MotionKindRescind        MotionKind = "rescind"
+	MotionKindAmend          MotionKind = "amend"
+	MotionKindRefer          MotionKind = "refer"
+	MotionKindPostpone       MotionKind = "postpone"
+	MotionKindTable          MotionKind = "table"
+	MotionKindCallQuestion   MotionKind = "call_question"
+	MotionKindAdjourn        MotionKind = "adjourn"
+	MotionKindRecess         MotionKind = "recess"
+	MotionKindPrivilege      MotionKind = "question_of_privilege"
+	MotionKindPointOfOrder   MotionKind = "point_of_order"
+	MotionKindAppeal         MotionKind = "appeal"
+	MotionKindSuspendRules   MotionKind = "suspend_rules"
+	MotionKindProcedure      MotionKind = "change_procedure"
+	MotionKindApproveMinutes MotionKind = "approve_minutes"
+)
+
+type MotionClass string
+
+const (
+	MotionClassMain        MotionClass = "main"
+	MotionClassSubsidiary  MotionClass = "subsidiary"
+	MotionClassPrivileged  MotionClass = "privileged"
+	MotionClassIncidental  MotionClass = "incidental"
+	MotionClassRestorative MotionClass = "restorative"
 )
 
 type Motion struct {
-	ID           primitive.ObjectID   `bson:"_id" json:"id"`
-	CommitteeID  primitive.ObjectID   `bson:"committee_id" json:"committee_id"`
-	MoverID      primitive.ObjectID   `bson:"mover_id" json:"mover_id"`
-	SeconderID   *primitive.ObjectID  `bson:"seconder_id,omitempty" json:"seconder_id,omitempty"`
-	Title        string               `bson:"title" json:"title"`
-	Description  string               `bson:"description" json:"description"`
-	Status       MotionStatus         `bson:"status" json:"status"`
-	Votes        []Vote               `bson:"votes" json:"votes"`
-	Comments     []Comment            `bson:"comments" json:"comments"`
-	IsSpecial    bool                 `bson:"is_special" json:"is_special"`
-	Summary      string               `bson:"summary,omitempty" json:"summary"`
-	CreatedAt    time.Time            `bson:"created_at" json:"created_at"`
-	UpdatedAt    time.Time            `bson:"updated_at" json:"updated_at"`
-	VotingEndsAt *time.Time           `bson:"voting_ends_at,omitempty" json:"voting_ends_at,omitempty"`
+	ID                 primitive.ObjectID  `bson:"_id" json:"id"`
+	CommitteeID        primitive.ObjectID  `bson:"committee_id" json:"committee_id"`
+	MoverID            primitive.ObjectID  `bson:"mover_id" json:"mover_id"`
+	Kind               MotionKind          `bson:"kind,omitempty" json:"kind,omitempty"`
+	RefMotionID        *primitive.ObjectID `bson:"ref_motion_id,omitempty" json:"ref_motion_id,omitempty"`
+	MeetingID          *primitive.ObjectID `bson:"meeting_id,omitempty" json:"meeting_id,omitempty"`
+	ParentMotionID     *primitive.ObjectID `bson:"parent_motion_id,omitempty" json:"parent_motion_id,omitempty"`
+	Amendment          *AmendmentText      `bson:"amendment,omitempty" json:"amendment,omitempty"`
+	PostponeUntil      *time.Time          `bson:"postpone_until,omitempty" json:"postpone_until,omitempty"`
+	ReferToCommitteeID *primitive.ObjectID `bson:"refer_to_committee_id,omitempty" json:"refer_to_committee_id,omitempty"`
+	DebateClosed       bool                `bson:"debate_closed,omitempty" json:"debate_closed,omitempty"`
+	Ruling             *ChairRuling        `bson:"ruling,omitempty" json:"ruling,omitempty"`
+	SettingsChange     *SettingsChange     `bson:"settings_change,omitempty" json:"settings_change,omitempty"`
+	MinutesID          *primitive.ObjectID `bson:"minutes_id,omitempty" json:"minutes_id,omitempty"`
+	SuspendedRule      SuspendedRule       `bson:"suspended_rule,omitempty" json:"suspended_rule,omitempty"`
+	MinutesVersion     int                 `bson:"minutes_version,omitempty" json:"minutes_version,omitempty"`
+	TextHistory        []TextRevision      `bson:"text_history,omitempty" json:"text_history,omitempty"`
+	SeconderID         *primitive.ObjectID `bson:"seconder_id,omitempty" json:"seconder_id,omitempty"`
+	Title              string              `bson:"title" json:"title"`
+	Description        string              `bson:"description" json:"description"`
+	Status             MotionStatus        `bson:"status" json:"status"`
+	Votes              []Vote              `bson:"votes" json:"votes"`
+	Comments           []Comment           `bson:"comments" json:"comments"`
+	IsSpecial          bool                `bson:"is_special" json:"is_special"`
+	Threshold          VoteThreshold       `bson:"threshold" json:"threshold"`
+	VoteMode           VoteMode            `bson:"vote_mode" json:"vote_mode"`
+	Summary            *DecisionSummary    `bson:"summary,omitempty" json:"summary,omitempty"`
+	CreatedAt          time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt          time.Time           `bson:"updated_at" json:"updated_at"`
+	SecondingEndsAt    *time.Time          `bson:"seconding_ends_at,omitempty" json:"seconding_ends_at,omitempty"`
+	DebateEndsAt       *time.Time          `bson:"debate_ends_at,omitempty" json:"debate_ends_at,omitempty"`
+	VotingEndsAt       *time.Time          `bson:"voting_ends_at,omitempty" json:"voting_ends_at,omitempty"`
+	RemindersSent      []DeadlinePhase     `bson:"reminders_sent,omitempty" json:"reminders_sent,omitempty"`
+	Tally              *VoteTally          `bson:"tally,omitempty" json:"tally,omitempty"`
+	EffectPending      bool                `bson:"effect_pending,omitempty" json:"effect_pending,omitempty"`
+	OverturnedBy       *primitive.ObjectID `bson:"overturned_by,omitempty" json:"overturned_by,omitempty"`
+	OverturnedAt       *time.Time          `bson:"overturned_at,omitempty" json:"overturned_at,omitempty"`
+}
+
+// AmendmentText is the wording an amendment would give its parent. An empty
+// title leaves the parent's title unchanged.
+type AmendmentText struct {
+	Title       string `bson:"title,omitempty" json:"title,omitempty"`
+	Description string `bson:"description" json:"description"`
+}
+
+// SuspendedRule is the rule of order a motion to suspend the rules sets
+// aside for the motion it refers to.
+type SuspendedRule string
+
+const (
+	// SuspendSecond lets a proposed motion go ahead without a second.
+	SuspendSecond SuspendedRule = "second"
+	// SuspendDebate ends debate so the vote can be taken straight away.
+	SuspendDebate SuspendedRule = "debate"
+)
+
+type RulingDecision string
+
+const (
+	RulingSustained    RulingDecision = "sustained"
+	RulingNotSustained RulingDecision = "not_sustained"
+)
+
+// ChairRuling is the chair's decision on a point of order or question of
+// privilege. An adopted appeal marks it overturned.
+type ChairRuling struct {
+	ChairID      primitive.ObjectID  `bson:"chair_id" json:"chair_id"`
+	Decision     RulingDecision      `bson:"decision" json:"decision"`
+	Explanation  string              `bson:"explanation,omitempty" json:"explanation,omitempty"`
+	RuledAt      time.Time           `bson:"ruled_at" json:"ruled_at"`
+	OverturnedBy *primitive.ObjectID `bson:"overturned_by,omitempty" json:"overturned_by,omitempty"`
+}
+
+type DiffOpType string
+
+const (
+	DiffEqual  DiffOpType = "equal"
+	DiffInsert DiffOpType = "insert"
+	DiffDelete DiffOpType = "delete"
+)
+
+type DiffOp struct {
+	Op   DiffOpType `bson:"op" json:"op"`
+	Text string     `bson:"text" json:"text"`
+}
+
+type TextRevision struct {
+	AmendmentID         primitive.ObjectID `bson:"amendment_id" json:"amendment_id"`
+	PreviousTitle       string             `bson:"previous_title" json:"previous_title"`
+	PreviousDescription string             `bson:"previous_description" json:"previous_description"`
+	TitleDiff           []DiffOp           `bson:"title_diff,omitempty" json:"title_diff,omitempty"`
+	DescriptionDiff     []DiffOp           `bson:"description_diff" json:"description_diff"`
+	AppliedAt           time.Time          `bson:"applied_at" json:"applied_at"`
+}
+
+type VoteTally struct {
+	Ayes        int                 `bson:"ayes" json:"ayes"`
+	Nays        int                 `bson:"nays" json:"nays"`
+	Abstentions int                 `bson:"abstentions" json:"abstentions"`
+	Threshold   VoteThreshold       `bson:"threshold,omitempty" json:"threshold,omitempty"`
+	Membership  int                 `bson:"membership" json:"membership"`
+	Present     int                 `bson:"present" json:"present"`
+	Required    int                 `bson:"required" json:"required"`
+	Rule        string              `bson:"rule,omitempty" json:"rule,omitempty"`
+	Result      MotionStatus        `bson:"result,omitempty" json:"result,omitempty"`
+	ClosedBy    *primitive.ObjectID `bson:"closed_by,omitempty" json:"closed_by,omitempty"`
+	DecidedAt   *time.Time          `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
+}
+
+// DecisionSummary is the chair's record of why a motion was decided the way
+// it was. It can be revised until LocksAt.
+type DecisionSummary struct {
+	Rationale string             `bson:"rationale" json:"rationale"`
+	Pros      []string           `bson:"pros" json:"pros"`
+	Cons      []string           `bson:"cons" json:"cons"`
+	AuthorID  primitive.ObjectID `bson:"author_id" json:"author_id"`
+	WrittenAt time.Time          `bson:"written_at" json:"written_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	LocksAt   time.Time          `bson:"locks_at" json:"locks_at"`
+}
+
+type Decision struct {
+	Motion       Motion           `json:"motion"`
+	StanceCounts StanceCounts     `json:"stanceCounts"`
+	Transcript   []*CommentThread `json:"transcript,omitempty"`
+	Votes        []Vote           `json:"votes,omitempty"`
+}
+
+type QueueEntry struct {
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Stance   Stance             `bson:"stance" json:"stance"`
+	RaisedAt time.Time          `bson:"raised_at" json:"raised_at"`
+}
+
+type SpeakingQueue struct {
+	MotionID    primitive.ObjectID `bson:"_id" json:"motion_id"`
+	CommitteeID primitive.ObjectID `bson:"committee_id" json:"committee_id"`
+	Entries     []QueueEntry       `bson:"entries" json:"entries"`
+	Speaker     *QueueEntry        `bson:"speaker,omitempty" json:"speaker,omitempty"`
+	LastStance  Stance             `bson:"last_stance,omitempty" json:"last_stance,omitempty"`
+	Alternate   bool               `bson:"alternate" json:"alternate"`
+	TimeLimit   int                `bson:"time_limit_seconds,omitempty" json:"time_limit_seconds,omitempty"`
+	FloorEndsAt *time.Time         `bson:"floor_ends_at,omitempty" json:"floor_ends_at,omitempty"`
+	Version     int64              `bson:"version" json:"version"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
 }
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebSocket protocol versions. Clients pick one with the Sec-WebSocket-Protocol
+// header when they connect; a client that asks for none speaks version 1.
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2
+
+	ProtocolVersion = ProtocolV2
+)
+
+// WSRequest is a frame sent by the client. The payload stays raw until the
+// action is known and it can be decoded into that action's struct.
+type WSRequest struct {
+	Action    string          `json:"action"`
+	Type      MessageType     `json:"type,omitempty"`
+	RequestID string          `json:"requestId,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+type WSErrorCode string
+
+const (
+	WSErrorValidation    WSErrorCode = "validation"
+	WSErrorPermission    WSErrorCode = "permission"
+	WSErrorNotFound      WSErrorCode = "not_found"
+	WSErrorConflict      WSErrorCode = "conflict"
+	WSErrorUnknownAction WSErrorCode = "unknown_action"
+	WSErrorInternal      WSErrorCode = "internal"
+)
+
+// WSAck answers a request that succeeded. Result holds whatever the action
+// produced for the caller; the rest of the room hears about it through the
+// usual broadcast.
+type WSAck struct {
+	Action string `json:"action"`
+	Result any    `json:"result,omitempty"`
+}
+
+// WSError answers a request that failed. Error carries the message that
+// version 1 clients already read.
+type WSError struct {
+	Action string      `json:"action"`
+	Code   WSErrorCode `json:"code"`
+	Error  string      `json:"error"`
+}
+
+// WSWelcome is the first frame on every connection.
+type WSWelcome struct {
+	Protocol  int   `json:"protocol"`
+	Supported []int `json:"supported"`
+}
+
+// RoomCursor marks a point in a room's event stream. Sequence numbers only
+// compare within one epoch; a new epoch starts whenever the server loses the
+// room's history, such as after a restart.
+type RoomCursor struct {
+	RoomID string `json:"roomId"`
+	Epoch  string `json:"epoch"`
+	Seq    uint64 `json:"seq"`
+}
+
+// WSResume reports how a room was caught up after a reconnect. When Resync
+// is set the missed events were no longer available and the client must
+// refetch the room's history. Presence is who is in the room now, since
+// comings and goings are not replayed.
+type WSResume struct {
+	RoomCursor
+	Replayed int            `json:"replayed"`
+	Resync   bool           `json:"resync"`
+	Presence []RoomPresence `json:"presence"`
+}
+
+// RoomPresence is one user in a room, across all their tabs and devices.
+type RoomPresence struct {
+	UserID      primitive.ObjectID `json:"userId"`
+	Connections int                `json:"connections"`
+	Typing      bool               `json:"typing"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CommitteeMode string
+
+const (
+	CommitteeModeAsync    CommitteeMode = "async"
+	CommitteeModeInPerson CommitteeMode = "in_person"
+)
+
+// QuorumRule sets how many voting members must be present for the committee
+// to do business, either as a fixed count or as a fraction of the membership.
+// An empty rule needs a majority of the members.
+type QuorumRule struct {
+	Count    int     `bson:"count,omitempty" json:"count,omitempty"`
+	Fraction float64 `bson:"fraction,omitempty" json:"fraction,omitempty"`
+}
+
+type CommitteeSettings struct {
+	CommitteeID       primitive.ObjectID  `bson:"_id" json:"committee_id"`
+	DefaultThreshold  VoteThreshold       `bson:"default_threshold" json:"default_threshold"`
+	RequireSeconds    bool                `bson:"require_seconds" json:"require_seconds"`
+	SecretByDefault   bool                `bson:"secret_by_default" json:"secret_by_default"`
+	SpeakingTimeLimit int                 `bson:"speaking_time_limit_seconds" json:"speaking_time_limit_seconds"`
+	AlternateSpeakers bool                `bson:"alternate_speakers" json:"alternate_speakers"`
+	Mode              CommitteeMode       `bson:"mode" json:"mode"`
+	Quorum            QuorumRule          `bson:"quorum" json:"quorum"`
+	SecondingWindow   int                 `bson:"seconding_window_seconds" json:"seconding_window_seconds"`
+	DebateWindow      int                 `bson:"debate_window_seconds" json:"debate_window_seconds"`
+	VotingWindow      int                 `bson:"voting_window_seconds" json:"voting_window_seconds"`
+	ReminderLead      int                 `bson:"reminder_lead_seconds" json:"reminder_lead_seconds"`
+	ProtectSettings   bool                `bson:"protect_settings" json:"protect_settings"`
+	UpdatedBy         *primitive.ObjectID `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+	UpdatedAt         *time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// SettingsChange is a partial update to a committee's settings. It doubles as
+// the request body and as the pending change carried by a change_procedure
+// motion.
+type SettingsChange struct {
+	DefaultThreshold  *VoteThreshold `bson:"default_threshold,omitempty" json:"defaultThreshold,omitempty"`
+	RequireSeconds    *bool          `bson:"require_seconds,omitempty" json:"requireSeconds,omitempty"`
+	SecretByDefault   *bool          `bson:"secret_by_default,omitempty" json:"secretByDefault,omitempty"`
+	SpeakingTimeLimit *int           `bson:"speaking_time_limit_seconds,omitempty" json:"speakingTimeLimitSeconds,omitempty"`
+	AlternateSpeakers *bool          `bson:"alternate_speakers,omitempty" json:"alternateSpeakers,omitempty"`
+	Mode              *CommitteeMode `bson:"mode,omitempty" json:"mode,omitempty"`
+	Quorum            *QuorumRule    `bson:"quorum,omitempty" json:"quorum,omitempty"`
+	SecondingWindow   *int           `bson:"seconding_window_seconds,omitempty" json:"secondingWindowSeconds,omitempty"`
+	DebateWindow      *int           `bson:"debate_window_seconds,omitempty" json:"debateWindowSeconds,omitempty"`
+	VotingWindow      *int           `bson:"voting_window_seconds,omitempty" json:"votingWindowSeconds,omitempty"`
+	ReminderLead      *int           `bson:"reminder_lead_seconds,omitempty" json:"reminderLeadSeconds,omitempty"`
+	ProtectSettings   *bool          `bson:"protect_settings,omitempty" json:"protectSettings,omitempty"`
+}
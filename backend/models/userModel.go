@@ -19,6 +19,13 @@ type PrivacySettings struct {
 	ShowFamilyName  bool `bson:"showFamilyName" json:"showFamilyName"`
 	ShowBio         bool `bson:"showBio" json:"showBio"`
 	ShowPicture     bool `bson:"showPicture" json:"showPicture"`
+	// ShowOnlineStatus is nil for users who have never chosen, who are
+	// shown online.
+	ShowOnlineStatus *bool `bson:"showOnlineStatus,omitempty" json:"showOnlineStatus,omitempty"`
+}
+
+func (p PrivacySettings) OnlineStatusShown() bool {
+	return p.ShowOnlineStatus == nil || *p.ShowOnlineStatus
 }
 
 type NotificationSettings struct {
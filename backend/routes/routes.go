@@ -59,6 +59,17 @@ func SetupRoutes(r *gin.Engine) {
 				}
 			}
 
+			invitations := me.Group("/invitations")
+			{
+				invitations.GET("", handlers.GetMyInvitations)
+
+				invitation := invitations.Group("/:invitationId")
+				{
+					invitation.POST("/accept", handlers.AcceptInvitation)
+					invitation.POST("/decline", handlers.DeclineInvitation)
+				}
+			}
+
 			comittees := me.Group("/comittees")
 			{
 				comittee := comittees.Group("/:comitteeId")
@@ -86,13 +97,92 @@ func SetupRoutes(r *gin.Engine) {
 		chat.POST("/dm/start", handlers.StartDMConversation)
 		chat.GET("/dm/:recipientId/history", handlers.GetDMHistory)
 		chat.GET("/conversations", handlers.GetUserConversations)
+		chat.GET("/rooms/:roomId/presence", handlers.GetRoomPresence)
 	}
 
 	committees := r.Group("/committees")
 	committees.Use(middleware.AuthMiddleware())
 	{
+		committees.POST("", handlers.CreateCommittee)
+		committees.GET("", handlers.GetMyCommittees)
+		committees.GET("/motion-kinds", handlers.GetMotionKinds)
+
 		committee := committees.Group("/:id")
 		{
+			committee.GET("", handlers.GetCommittee)
+			committee.PATCH("", handlers.UpdateCommittee)
+			committee.POST("/archive", handlers.ArchiveCommittee)
+			committee.DELETE("", handlers.DeleteCommittee)
+
+			committee.GET("/invitations", handlers.GetCommitteeInvitations)
+			committee.POST("/invitations", handlers.InviteCommitteeMember)
+			committee.POST("/leave", handlers.LeaveCommittee)
+			committee.DELETE("/members/:userId", handlers.RemoveCommitteeMember)
+
+			committee.GET("/decisions", handlers.GetDecisions)
+			committee.GET("/decisions/:motionId", handlers.GetDecision)
+			committee.PUT("/decisions/:motionId/summary", handlers.WriteDecisionSummary)
+
+			meetings := committee.Group("/meetings")
+			{
+				meetings.GET("", handlers.GetMeetings)
+				meetings.POST("", handlers.ScheduleMeeting)
+				meetings.GET("/:meetingId", handlers.GetMeeting)
+				meetings.POST("/:meetingId/call-to-order", handlers.CallMeetingToOrder)
+				meetings.POST("/:meetingId/advance", handlers.AdvanceAgenda)
+				meetings.POST("/:meetingId/adjourn", handlers.AdjournMeeting)
+				meetings.POST("/:meetingId/check-in", handlers.CheckInToMeeting)
+				meetings.POST("/:meetingId/check-out", handlers.CheckOutOfMeeting)
+				meetings.GET("/:meetingId/minutes", handlers.GetMinutes)
+			}
+
+			committee.GET("/quorum", handlers.GetQuorum)
+			committee.GET("/settings", handlers.GetCommitteeSettings)
+			committee.PUT("/settings", handlers.UpdateCommitteeSettings)
+			committee.GET("/control-panel", handlers.GetControlPanel)
+			committee.GET("/audit", handlers.GetAuditLog)
+			committee.GET("/audit/verify", handlers.VerifyAuditLog)
+
+			minutes := committee.Group("/minutes")
+			{
+				minutes.GET("", handlers.GetMinutes)
+				minutes.GET("/drafts", handlers.GetMinutesDrafts)
+				minutes.POST("/drafts", handlers.DraftMinutes)
+				minutes.GET("/drafts/:minutesId", handlers.GetMinutesDraft)
+				minutes.PUT("/drafts/:minutesId", handlers.EditMinutes)
+				minutes.POST("/drafts/:minutesId/corrections", handlers.ProposeMinutesCorrection)
+				minutes.POST("/drafts/:minutesId/corrections/:correctionId/accept", handlers.AcceptMinutesCorrection)
+				minutes.POST("/drafts/:minutesId/corrections/:correctionId/reject", handlers.RejectMinutesCorrection)
+				minutes.POST("/drafts/:minutesId/approve", handlers.MoveToApproveMinutes)
+			}
+
+			motions := committee.Group("/motions")
+			{
+				motions.GET("", handlers.GetMotions)
+				motions.POST("", handlers.ProposeMotion)
+
+				motion := motions.Group("/:motionId")
+				{
+					motion.GET("", handlers.GetMotion)
+					motion.PATCH("", handlers.UpdateMotion)
+					motion.DELETE("", handlers.DeleteMotion)
+					motion.POST("/second", handlers.SecondMotion)
+					motion.POST("/status", handlers.ChangeMotionStatus)
+					motion.GET("/pending", handlers.GetPendingQuestions)
+					motion.POST("/ruling", handlers.RuleOnMotion)
+					motion.GET("/votes", handlers.GetMotionVotes)
+					motion.POST("/votes", handlers.CastVote)
+					motion.POST("/close-voting", handlers.CloseVoting)
+					motion.PUT("/voting-deadline", handlers.SetVotingDeadline)
+
+					motion.GET("/comments", handlers.GetMotionComments)
+					motion.POST("/comments", handlers.CreateComment)
+					motion.GET("/comments/:commentId", handlers.GetComment)
+					motion.PATCH("/comments/:commentId", handlers.UpdateComment)
+					motion.DELETE("/comments/:commentId", handlers.DeleteComment)
+				}
+			}
+
 			committee.POST("/chat/start", handlers.StartCommitteeChat)
 			committee.GET("/chat/history", handlers.GetCommitteeHistory)
 		}
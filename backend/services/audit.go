@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrAuditContention = errors.New("could not append to the audit log")
+
+// AuditChain names the chain a committee's actions are recorded in. Rooms
+// outside a committee get a chain of their own.
+func AuditChain(committeeID primitive.ObjectID) string {
+	return "committee:" + committeeID.Hex()
+}
+
+func roomAuditChain(roomID string) string {
+	if committeeID, ok := models.ParseCommitteeRoomID(roomID); ok {
+		return AuditChain(committeeID)
+	}
+	return "room:" + roomID
+}
+
+// EnsureAuditIndexes makes (chain, sequence) unique, which is what keeps two
+// writers from appending the same link.
+func EnsureAuditIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("audit_log").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "chain", Value: 1}, {Key: "sequence", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// hashAuditEntry hashes a fixed-order encoding of the entry. Times are
+// rounded to the millisecond MongoDB stores so a reloaded entry hashes the
+// same; json sorts the detail keys.
+func hashAuditEntry(entry *models.AuditEntry) string {
+	// Empty details are not stored, so nil and empty must hash alike.
+	var details []byte
+	if len(entry.Details) > 0 {
+		details, _ = json.Marshal(entry.Details)
+	}
+	fields := []string{
+		entry.PrevHash,
+		entry.Chain,
+		fmt.Sprint(entry.Sequence),
+		entry.ID.Hex(),
+		string(entry.Action),
+		optionalHex(entry.ActorID),
+		optionalHex(entry.SubjectID),
+		string(details),
+		entry.CreatedAt.UTC().Truncate(time.Millisecond).Format(time.RFC3339Nano),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+func optionalHex(id *primitive.ObjectID) string {
+	if id == nil {
+		return ""
+	}
+	return id.Hex()
+}
+
+// auditHead is the head recorded for a chain. A chain started before heads
+// were recorded falls back to its last entry.
+func auditHead(ctx context.Context, chain string) (*models.AuditHead, error) {
+	head, err := recordedAuditHead(ctx, chain)
+	if err != nil || head != nil {
+		return head, err
+	}
+
+	last, err := lastAuditEntry(ctx, chain)
+	if err != nil || last == nil {
+		return nil, err
+	}
+	return &models.AuditHead{Chain: chain, Sequence: last.Sequence, Hash: last.Hash}, nil
+}
+
+// advanceAuditHead moves the chain's head forward to the entry. The head
+// never moves back, so a writer that lost the race leaves it alone.
+func advanceAuditHead(ctx context.Context, entry *models.AuditEntry) error {
+	_, err := config.GetCollection("audit_heads").UpdateOne(ctx,
+		bson.M{"_id": entry.Chain, "sequence": bson.M{"$lt": entry.Sequence}},
+		bson.M{"$set": bson.M{"sequence": entry.Sequence, "hash": entry.Hash, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func lastAuditEntry(ctx context.Context, chain string) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	opts := options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})
+	err := config.GetCollection("audit_log").FindOne(ctx, bson.M{"chain": chain}, opts).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// AppendAudit links an entry onto the end of its chain and then moves the
+// chain's head up to it. Losing a race for the next sequence number just
+// means reading the new head and trying again, for as long as the context
+// allows.
+func AppendAudit(ctx context.Context, chain string, action models.AuditAction, actorID, subjectID *primitive.ObjectID, details map[string]string) (*models.AuditEntry, error) {
+	for ctx.Err() == nil {
+		head, err := auditHead(ctx, chain)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := models.AuditEntry{
+			ID:        primitive.NewObjectID(),
+			Chain:     chain,
+			Sequence:  1,
+			Action:    action,
+			ActorID:   actorID,
+			SubjectID: subjectID,
+			Details:   details,
+			CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+		}
+		if head != nil {
+			entry.Sequence = head.Sequence + 1
+			entry.PrevHash = head.Hash
+		}
+		entry.Hash = hashAuditEntry(&entry)
+
+		_, err = config.GetCollection("audit_log").InsertOne(ctx, entry)
+		if mongo.IsDuplicateKeyError(err) {
+			// The winner may not have moved the head yet, or may have
+			// failed to; bring it up to the tail before trying again.
+			if err := catchUpAuditHead(ctx, chain); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := advanceAuditHead(ctx, &entry); err != nil {
+			return nil, err
+		}
+		return &entry, nil
+	}
+	return nil, ErrAuditContention
+}
+
+func catchUpAuditHead(ctx context.Context, chain string) error {
+	last, err := lastAuditEntry(ctx, chain)
+	if err != nil || last == nil {
+		return err
+	}
+	return advanceAuditHead(ctx, last)
+}
+
+// audit records an action that has already happened. A failure is logged
+// and returned; votes, motions and minutes pass it on so the caller learns
+// the action went unrecorded, while lesser actions only log it.
+func audit(ctx context.Context, chain string, action models.AuditAction, actorID, subjectID *primitive.ObjectID, details map[string]string) error {
+	if _, err := AppendAudit(ctx, chain, action, actorID, subjectID, details); err != nil {
+		log.Printf("Error appending %s to audit chain %s: %v", action, chain, err)
+		return err
+	}
+	return nil
+}
+
+func AuditCommittee(ctx context.Context, committeeID primitive.ObjectID, action models.AuditAction, actorID, subjectID *primitive.ObjectID, details map[string]string) error {
+	return audit(ctx, AuditChain(committeeID), action, actorID, subjectID, details)
+}
+
+func AuditRoom(ctx context.Context, roomID string, action models.AuditAction, actorID, subjectID *primitive.ObjectID, details map[string]string) error {
+	return audit(ctx, roomAuditChain(roomID), action, actorID, subjectID, details)
+}
+
+// ListAudit pages through a chain in order, starting after the given
+// sequence number.
+func ListAudit(ctx context.Context, chain string, after int64, limit int64) ([]models.AuditEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}).SetLimit(limit)
+	cursor, err := config.GetCollection("audit_log").Find(ctx, bson.M{
+		"chain":    chain,
+		"sequence": bson.M{"$gt": after},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// VerifyAuditChain walks a chain from the start, recomputing every hash, and
+// reports the first entry that is missing, out of place or altered. The walk
+// must also reach the recorded head, or entries were cut from the end.
+func VerifyAuditChain(ctx context.Context, chain string) (*models.AuditVerification, error) {
+	// The head is read first: entries appended during the walk only take
+	// the chain past it.
+	head, err := recordedAuditHead(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}})
+	cursor, err := config.GetCollection("audit_log").Find(ctx, bson.M{"chain": chain}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := &models.AuditVerification{Chain: chain, Valid: true}
+	prevHash := ""
+	for cursor.Next(ctx) {
+		var entry models.AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, err
+		}
+		result.Entries++
+
+		if result.FirstBreak == nil {
+			reason := checkAuditLink(&entry, result.Entries, prevHash)
+			if reason == "" && head != nil && entry.Sequence == head.Sequence && entry.Hash != head.Hash {
+				reason = "does not match the recorded head of the chain"
+			}
+			if reason != "" {
+				result.Valid = false
+				result.FirstBreak = &models.AuditBreak{
+					Sequence: result.Entries,
+					EntryID:  entry.ID,
+					Reason:   reason,
+				}
+			}
+		}
+		prevHash = entry.Hash
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	if result.FirstBreak == nil {
+		if head == nil && result.Entries > 0 {
+			// The first append may have landed after the head was read.
+			if head, err = recordedAuditHead(ctx, chain); err != nil {
+				return nil, err
+			}
+		}
+		if reason := checkAuditHead(head, result.Entries); reason != "" {
+			result.Valid = false
+			result.FirstBreak = &models.AuditBreak{Sequence: result.Entries + 1, Reason: reason}
+		}
+	}
+
+	result.HeadHash = prevHash
+	result.VerifiedAt = time.Now()
+	return result, nil
+}
+
+func recordedAuditHead(ctx context.Context, chain string) (*models.AuditHead, error) {
+	var head models.AuditHead
+	err := config.GetCollection("audit_heads").FindOne(ctx, bson.M{"_id": chain}).Decode(&head)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+func checkAuditHead(head *models.AuditHead, entries int64) string {
+	switch {
+	case head == nil && entries > 0:
+		return "the chain has entries but no recorded head"
+	case head != nil && entries < head.Sequence:
+		return fmt.Sprintf("the chain ends at entry %d but its head records entry %d; entries were removed from the end", entries, head.Sequence)
+	}
+	return ""
+}
+
+func checkAuditLink(entry *models.AuditEntry, want int64, prevHash string) string {
+	switch {
+	case entry.Sequence != want:
+		return fmt.Sprintf("expected entry %d but found %d; entries are missing or were inserted", want, entry.Sequence)
+	case entry.PrevHash != prevHash:
+		return "does not link to the previous entry"
+	case entry.Hash != hashAuditEntry(entry):
+		return "contents do not match the recorded hash"
+	}
+	return ""
+}
+
+// AuditRoleChange records a member's role moving from one value to another.
+// An empty role means they were not on the committee.
+func AuditRoleChange(ctx context.Context, committeeID primitive.ObjectID, actorID, memberID primitive.ObjectID, from, to models.CommitteeRole) {
+	AuditCommittee(ctx, committeeID, models.AuditRoleChanged, &actorID, &memberID, map[string]string{
+		"from": string(from),
+		"to":   string(to),
+	})
+}
+
+// Message entries carry hashes of the text rather than the text itself, so a
+// direct message's audit trail proves what changed without repeating it.
+func AuditMessageEdit(ctx context.Context, message *models.Message, actorID primitive.ObjectID, content string) {
+	AuditRoom(ctx, message.RoomID, models.AuditMessageEdited, &actorID, &message.ID, map[string]string{
+		"room_id":       message.RoomID,
+		"previous_hash": contentHash(message.Content),
+		"content_hash":  contentHash(content),
+	})
+}
+
+func AuditMessageDelete(ctx context.Context, message *models.Message, actorID primitive.ObjectID) {
+	AuditRoom(ctx, message.RoomID, models.AuditMessageDeleted, &actorID, &message.ID, map[string]string{
+		"room_id":      message.RoomID,
+		"content_hash": contentHash(message.Content),
+	})
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidVoteMode = errors.New("vote mode must be roll_call or secret")
+	ErrAlreadyVoted    = errors.New("a secret ballot has already been cast for this member")
+)
+
+func ResolveVoteMode(requested models.VoteMode) (models.VoteMode, error) {
+	switch requested {
+	case "":
+		return models.VoteModeRollCall, nil
+	case models.VoteModeRollCall, models.VoteModeSecret:
+		return requested, nil
+	}
+	return "", ErrInvalidVoteMode
+}
+
+func IsSecretBallot(motion *models.Motion) bool {
+	return motion.VoteMode == models.VoteModeSecret
+}
+
+// castSecretBallot stores who voted and what was voted in two places that
+// share nothing but the motion: a participation record keyed by member, and
+// an anonymous counter on the motion's ballot box. Secret ballots cannot be
+// changed once cast because nothing links a member back to their choice. The
+// participation index keeps two racing ballots from the same member from
+// both reaching the counter.
+func castSecretBallot(ctx context.Context, motion *models.Motion, userID primitive.ObjectID, result models.VoteResult) error {
+	participation := config.GetCollection("vote_participation")
+	res, err := participation.UpdateOne(ctx,
+		bson.M{"motion_id": motion.ID, "user_id": userID},
+		bson.M{"$setOnInsert": bson.M{
+			"_id":        primitive.NewObjectID(),
+			"created_at": time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyVoted
+	}
+	if err != nil {
+		return err
+	}
+	if res.UpsertedCount == 0 {
+		return ErrAlreadyVoted
+	}
+
+	_, err = config.GetCollection("ballot_boxes").UpdateOne(ctx,
+		bson.M{"_id": motion.ID},
+		bson.M{"$inc": bson.M{ballotField(result): 1}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		participation.DeleteOne(ctx, bson.M{"motion_id": motion.ID, "user_id": userID})
+		return err
+	}
+	return nil
+}
+
+// EnsureBallotIndexes lets each member take part in a secret ballot once.
+func EnsureBallotIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("vote_participation").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "motion_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func ballotField(result models.VoteResult) string {
+	switch result {
+	case models.VoteAye:
+		return "ayes"
+	case models.VoteNay:
+		return "nays"
+	}
+	return "abstentions"
+}
+
+func BallotsCast(ctx context.Context, motionID primitive.ObjectID) (int64, error) {
+	return config.GetCollection("vote_participation").CountDocuments(ctx, bson.M{"motion_id": motionID})
+}
+
+func secretTally(ctx context.Context, motionID primitive.ObjectID) (models.VoteTally, int, error) {
+	var box models.BallotBox
+	err := config.GetCollection("ballot_boxes").FindOne(ctx, bson.M{"_id": motionID}).Decode(&box)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return models.VoteTally{}, 0, err
+	}
+
+	cast, err := BallotsCast(ctx, motionID)
+	if err != nil {
+		return models.VoteTally{}, 0, err
+	}
+
+	return models.VoteTally{
+		Ayes:        box.Ayes,
+		Nays:        box.Nays,
+		Abstentions: box.Abstentions,
+	}, int(cast), nil
+}
+
+// voterIDs lists who has voted on a motion. For a secret ballot this comes
+// from the participation records, which say nothing about how anyone voted.
+func voterIDs(ctx context.Context, motion *models.Motion) (map[primitive.ObjectID]bool, error) {
+	voted := make(map[primitive.ObjectID]bool)
+	if IsSecretBallot(motion) {
+		cursor, err := config.GetCollection("vote_participation").Find(ctx, bson.M{"motion_id": motion.ID})
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		var records []models.VoteParticipation
+		if err := cursor.All(ctx, &records); err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			voted[record.UserID] = true
+		}
+		return voted, nil
+	}
+
+	votes, err := GetVotes(ctx, motion.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, vote := range votes {
+		voted[vote.UserID] = true
+	}
+	return voted, nil
+}
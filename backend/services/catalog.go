@@ -0,0 +1,162 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/zach-short/final-web-programming/models"
+)
+
+// MotionSpec describes how the engine treats a kind of motion. Rank orders
+// motions that can interrupt a pending main motion; a motion is only in order
+// when it outranks everything already pending. Incidental motions have no
+// rank and are in order whenever they arise.
+type MotionSpec struct {
+	Kind           models.MotionKind    `json:"kind"`
+	Class          models.MotionClass   `json:"class"`
+	Rank           int                  `json:"rank"`
+	RequiresSecond bool                 `json:"requiresSecond"`
+	Debatable      bool                 `json:"debatable"`
+	Amendable      bool                 `json:"amendable"`
+	ChairRules     bool                 `json:"chairRules"`
+	Threshold      models.VoteThreshold `json:"threshold,omitempty"`
+	Description    string               `json:"description"`
+}
+
+var motionCatalog = map[models.MotionKind]MotionSpec{
+	models.MotionKindMain: {
+		Class: models.MotionClassMain, RequiresSecond: true, Debatable: true, Amendable: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Brings new business before the committee",
+	},
+	models.MotionKindReconsider: {
+		Class: models.MotionClassRestorative, RequiresSecond: true, Debatable: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Reopens a decision; moved by a member of the prevailing side",
+	},
+	models.MotionKindRescind: {
+		Class: models.MotionClassRestorative, RequiresSecond: true, Debatable: true, Amendable: true,
+		Threshold:   models.ThresholdTwoThirds,
+		Description: "Cancels a previous decision; moved by a member of the prevailing side",
+	},
+	models.MotionKindAmend: {
+		Class: models.MotionClassSubsidiary, Rank: 2, RequiresSecond: true, Debatable: true, Amendable: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Changes the wording of the pending motion",
+	},
+	models.MotionKindRefer: {
+		Class: models.MotionClassSubsidiary, Rank: 3, RequiresSecond: true, Debatable: true, Amendable: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Sends the pending motion to a committee",
+	},
+	models.MotionKindPostpone: {
+		Class: models.MotionClassSubsidiary, Rank: 4, RequiresSecond: true, Debatable: true, Amendable: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Puts off the pending motion to a set time",
+	},
+	models.MotionKindCallQuestion: {
+		Class: models.MotionClassSubsidiary, Rank: 6, RequiresSecond: true,
+		Threshold:   models.ThresholdTwoThirds,
+		Description: "Ends debate on the pending motion",
+	},
+	models.MotionKindTable: {
+		Class: models.MotionClassSubsidiary, Rank: 7, RequiresSecond: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Sets the pending motion aside temporarily",
+	},
+	models.MotionKindPrivilege: {
+		Class: models.MotionClassPrivileged, Rank: 9, ChairRules: true,
+		Description: "Raises an urgent matter of rights or comfort for the chair to decide",
+	},
+	models.MotionKindRecess: {
+		Class: models.MotionClassPrivileged, Rank: 10, RequiresSecond: true, Amendable: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Takes a short break without closing the meeting",
+	},
+	models.MotionKindAdjourn: {
+		Class: models.MotionClassPrivileged, Rank: 11, RequiresSecond: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Closes the meeting",
+	},
+	models.MotionKindPointOfOrder: {
+		Class: models.MotionClassIncidental, ChairRules: true,
+		Description: "Asks the chair to enforce the rules",
+	},
+	models.MotionKindAppeal: {
+		Class: models.MotionClassIncidental, RequiresSecond: true, Debatable: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Asks the committee to overturn a ruling of the chair; a tie sustains the chair",
+	},
+	models.MotionKindSuspendRules: {
+		Class: models.MotionClassIncidental, RequiresSecond: true,
+		Threshold:   models.ThresholdTwoThirds,
+		Description: "Lets a pending motion go ahead without a second, or ends its debate",
+	},
+	models.MotionKindProcedure: {
+		Class: models.MotionClassMain, RequiresSecond: true, Debatable: true,
+		Threshold:   models.ThresholdTwoThirds,
+		Description: "Changes the committee's procedural settings when they are protected",
+	},
+	models.MotionKindApproveMinutes: {
+		Class: models.MotionClassMain, Debatable: true,
+		Threshold:   models.ThresholdMajority,
+		Description: "Approves a draft of the minutes, freezing that version; changes are made by correction",
+	},
+}
+
+func init() {
+	for kind, spec := range motionCatalog {
+		spec.Kind = kind
+		motionCatalog[kind] = spec
+	}
+}
+
+// SpecFor looks up a motion kind, treating an empty kind as a main motion so
+// motions stored before kinds existed keep their behavior.
+func SpecFor(kind models.MotionKind) (MotionSpec, bool) {
+	if kind == "" {
+		kind = models.MotionKindMain
+	}
+	spec, ok := motionCatalog[kind]
+	return spec, ok
+}
+
+func MotionCatalog() []MotionSpec {
+	specs := make([]MotionSpec, 0, len(motionCatalog))
+	for _, spec := range motionCatalog {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		if specs[i].Rank != specs[j].Rank {
+			return specs[i].Rank < specs[j].Rank
+		}
+		return specs[i].Kind < specs[j].Kind
+	})
+	return specs
+}
+
+func IsSubsidiaryKind(kind models.MotionKind) bool {
+	spec, _ := SpecFor(kind)
+	return spec.Class == models.MotionClassSubsidiary
+}
+
+func MotionRank(kind models.MotionKind) int {
+	spec, _ := SpecFor(kind)
+	return spec.Rank
+}
+
+// isMainClass reports whether a motion can have subsidiary and privileged
+// motions attached to it.
+func isMainClass(kind models.MotionKind) bool {
+	spec, _ := SpecFor(kind)
+	return spec.Class == models.MotionClassMain || spec.Class == models.MotionClassRestorative
+}
+
+// requiresSecond lets a committee waive seconds for every kind of motion.
+func requiresSecond(spec MotionSpec, settings *models.CommitteeSettings) bool {
+	return spec.RequiresSecond && settings.RequireSeconds
+}
+
+func isDebatable(motion *models.Motion) bool {
+	spec, _ := SpecFor(motion.Kind)
+	return spec.Debatable
+}
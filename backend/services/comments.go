@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCommentNotFound     = errors.New("comment not found")
+	ErrCommentContent      = errors.New("comment content is required")
+	ErrStanceRequired      = errors.New("every reply must be tagged pro, con or neutral")
+	ErrNotCommentAuthor    = errors.New("can only change your own comments")
+	ErrDebateLocked        = errors.New("debate is closed on this motion")
+	ErrNotDebatable        = errors.New("this kind of motion is not debatable")
+	ErrParentCommentMotion = errors.New("parent comment belongs to a different motion")
+)
+
+type PostCommentInput struct {
+	Content  string
+	Stance   models.Stance
+	ParentID *primitive.ObjectID
+}
+
+func DebateOpen(motion *models.Motion) bool {
+	return motion.Status == models.MotionStatusOpen && !motion.DebateClosed && isDebatable(motion) &&
+		!debateExpired(motion, time.Now())
+}
+
+func GetComment(ctx context.Context, commentID primitive.ObjectID) (*models.Comment, error) {
+	var comment models.Comment
+	err := config.GetCollection("comments").FindOne(ctx, bson.M{"_id": commentID}).Decode(&comment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrCommentNotFound
+		}
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func ListComments(ctx context.Context, motionID primitive.ObjectID) ([]models.Comment, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := config.GetCollection("comments").Find(ctx, bson.M{"motion_id": motionID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	comments := []models.Comment{}
+	if err := cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// BuildCommentThreads nests replies under their parents, keeping creation
+// order. Replies whose parent is missing are promoted to the top level.
+func BuildCommentThreads(comments []models.Comment) []*models.CommentThread {
+	nodes := make(map[primitive.ObjectID]*models.CommentThread, len(comments))
+	for _, comment := range comments {
+		nodes[comment.ID] = &models.CommentThread{Comment: comment, Replies: []*models.CommentThread{}}
+	}
+
+	threads := []*models.CommentThread{}
+	for _, comment := range comments {
+		node := nodes[comment.ID]
+		if comment.ParentID != nil {
+			if parent, ok := nodes[*comment.ParentID]; ok {
+				parent.Replies = append(parent.Replies, node)
+				continue
+			}
+		}
+		threads = append(threads, node)
+	}
+	return threads
+}
+
+func CountStances(comments []models.Comment) models.StanceCounts {
+	var counts models.StanceCounts
+	for _, comment := range comments {
+		if comment.IsDeleted {
+			continue
+		}
+		switch comment.Stance {
+		case models.StancePro:
+			counts.Pro++
+		case models.StanceCon:
+			counts.Con++
+		case models.StanceNeutral:
+			counts.Neutral++
+		}
+	}
+	return counts
+}
+
+func MotionStanceCounts(ctx context.Context, motionID primitive.ObjectID) (models.StanceCounts, error) {
+	comments, err := ListComments(ctx, motionID)
+	if err != nil {
+		return models.StanceCounts{}, err
+	}
+	return CountStances(comments), nil
+}
+
+// stanceCountsByMotion counts the stances of several motions' debates in one
+// query. Motions with no debate are left out of the map.
+func stanceCountsByMotion(ctx context.Context, motionIDs []primitive.ObjectID) (map[primitive.ObjectID]models.StanceCounts, error) {
+	cursor, err := config.GetCollection("comments").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"motion_id":  bson.M{"$in": motionIDs},
+			"is_deleted": bson.M{"$ne": true},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"motion": "$motion_id", "stance": "$stance"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		ID struct {
+			Motion primitive.ObjectID `bson:"motion"`
+			Stance models.Stance      `bson:"stance"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[primitive.ObjectID]models.StanceCounts)
+	for _, group := range groups {
+		c := counts[group.ID.Motion]
+		switch group.ID.Stance {
+		case models.StancePro:
+			c.Pro += group.Count
+		case models.StanceCon:
+			c.Con += group.Count
+		case models.StanceNeutral:
+			c.Neutral += group.Count
+		}
+		counts[group.ID.Motion] = c
+	}
+	return counts, nil
+}
+
+func PostComment(ctx context.Context, motion *models.Motion, userID primitive.ObjectID, input PostCommentInput) (*models.Comment, error) {
+	if _, _, err := Authorize(ctx, motion.CommitteeID, userID, CapDebate); err != nil {
+		return nil, err
+	}
+	if !isDebatable(motion) {
+		return nil, ErrNotDebatable
+	}
+	if !DebateOpen(motion) {
+		return nil, ErrDebateLocked
+	}
+
+	content := strings.TrimSpace(input.Content)
+	if content == "" {
+		return nil, ErrCommentContent
+	}
+	if input.Stance == "" {
+		return nil, ErrStanceRequired
+	}
+	if !ValidStance(input.Stance) {
+		return nil, ErrInvalidStance
+	}
+
+	if input.ParentID != nil {
+		parent, err := GetComment(ctx, *input.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.MotionID != motion.ID {
+			return nil, ErrParentCommentMotion
+		}
+	}
+
+	comment := models.Comment{
+		ID:        primitive.NewObjectID(),
+		MotionID:  motion.ID,
+		UserID:    userID,
+		ParentID:  input.ParentID,
+		Stance:    input.Stance,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := config.GetCollection("comments").InsertOne(ctx, comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// EditComment follows the message rules: only the author may edit, and the
+// first version is kept as the original content.
+func EditComment(ctx context.Context, motion *models.Motion, comment *models.Comment, userID primitive.ObjectID, content *string, stance *models.Stance) (*models.Comment, error) {
+	if comment.UserID != userID || comment.IsDeleted {
+		return nil, ErrNotCommentAuthor
+	}
+	if !DebateOpen(motion) {
+		return nil, ErrDebateLocked
+	}
+
+	now := time.Now()
+	set := bson.M{}
+	if content != nil {
+		trimmed := strings.TrimSpace(*content)
+		if trimmed == "" {
+			return nil, ErrCommentContent
+		}
+		set["content"] = trimmed
+		set["is_edited"] = true
+		set["edited_at"] = now
+		if !comment.IsEdited {
+			set["original_content"] = comment.Content
+		}
+	}
+	if stance != nil {
+		if !ValidStance(*stance) {
+			return nil, ErrInvalidStance
+		}
+		set["stance"] = *stance
+	}
+	if len(set) == 0 {
+		return comment, nil
+	}
+
+	var updated models.Comment
+	err := config.GetCollection("comments").FindOneAndUpdate(ctx,
+		bson.M{"_id": comment.ID},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrCommentNotFound
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteComment removes the author's comment. A comment that has replies is
+// blanked instead so the rest of the thread stays attached.
+func DeleteComment(ctx context.Context, motion *models.Motion, comment *models.Comment, userID primitive.ObjectID) error {
+	if comment.UserID != userID || comment.IsDeleted {
+		return ErrNotCommentAuthor
+	}
+	if !DebateOpen(motion) {
+		return ErrDebateLocked
+	}
+
+	collection := config.GetCollection("comments")
+	replies, err := collection.CountDocuments(ctx, bson.M{"parent_id": comment.ID})
+	if err != nil {
+		return err
+	}
+
+	if replies > 0 {
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": comment.ID}, bson.M{
+			"$set":   bson.M{"is_deleted": true, "content": ""},
+			"$unset": bson.M{"original_content": ""},
+		})
+		return err
+	}
+
+	_, err = collection.DeleteOne(ctx, bson.M{"_id": comment.ID})
+	return err
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeleteCommittee removes a committee and everything recorded under it. The
+// committee itself goes last, so a delete that fails partway can be retried.
+// The audit log is kept; it is the record that the committee existed.
+func DeleteCommittee(ctx context.Context, committeeID primitive.ObjectID) error {
+	motionIDs, err := idsOf(ctx, "motions", bson.M{"committee_id": committeeID})
+	if err != nil {
+		return err
+	}
+	minutesIDs, err := idsOf(ctx, "minutes", bson.M{"committee_id": committeeID})
+	if err != nil {
+		return err
+	}
+	notificationIDs, err := idsOf(ctx, "notifications", bson.M{
+		"related_id": bson.M{"$in": append(motionIDs, committeeID)},
+	})
+	if err != nil {
+		return err
+	}
+
+	byMotion := bson.M{"motion_id": bson.M{"$in": motionIDs}}
+	deletes := []struct {
+		collection string
+		filter     bson.M
+	}{
+		{"votes", byMotion},
+		{"vote_participation", byMotion},
+		{"comments", byMotion},
+		{"ballot_boxes", bson.M{"_id": bson.M{"$in": motionIDs}}},
+		{"speaking_queues", bson.M{"_id": bson.M{"$in": motionIDs}}},
+		{"motions", bson.M{"committee_id": committeeID}},
+		{"minutes_corrections", bson.M{"minutes_id": bson.M{"$in": minutesIDs}}},
+		{"minutes", bson.M{"committee_id": committeeID}},
+		{"meetings", bson.M{"committee_id": committeeID}},
+		{"committee_settings", bson.M{"_id": committeeID}},
+		{"committee_invitations", bson.M{"committee_id": committeeID}},
+		{"user_notifications", bson.M{"notification_id": bson.M{"$in": notificationIDs}}},
+		{"notifications", bson.M{"_id": bson.M{"$in": notificationIDs}}},
+		{"messages", bson.M{"roomId": models.CreateCommitteeRoomID(committeeID)}},
+		{"committees", bson.M{"_id": committeeID}},
+	}
+	for _, d := range deletes {
+		if _, err := config.GetCollection(d.collection).DeleteMany(ctx, d.filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func idsOf(ctx context.Context, collection string, filter bson.M) ([]primitive.ObjectID, error) {
+	cursor, err := config.GetCollection(collection).Find(ctx, filter,
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids, nil
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrVotingNotStarted = errors.New("voting opens when the debate window closes")
+
+// DeadlineListener is told whenever a motion's deadlines change so the
+// scheduler can wake up for them.
+type DeadlineListener func(motionID primitive.ObjectID)
+
+var deadlinesChanged DeadlineListener = func(primitive.ObjectID) {}
+
+func SetDeadlineListener(listener DeadlineListener) {
+	deadlinesChanged = listener
+}
+
+type Deadline struct {
+	Phase  models.DeadlinePhase
+	EndsAt time.Time
+}
+
+type DeadlineReminder struct {
+	Deadline
+	Recipients []primitive.ObjectID
+}
+
+// DeadlineResult is what ProcessDeadlines did to a motion.
+type DeadlineResult struct {
+	Motion       *models.Motion
+	Expired      bool
+	DebateClosed bool
+	VotingClosed bool
+	// EffectApplied is set when a passed motion's effect, which failed
+	// when voting closed, has now been applied.
+	EffectApplied bool
+	Reminders     []DeadlineReminder
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+// deadlineAt drops what Mongo cannot store so a deadline compares equal after
+// a round trip.
+func deadlineAt(t time.Time) *time.Time {
+	t = t.Truncate(time.Millisecond)
+	return &t
+}
+
+func secondingDeadline(settings *models.CommitteeSettings, now time.Time) *time.Time {
+	if settings.Mode != models.CommitteeModeAsync || settings.SecondingWindow == 0 {
+		return nil
+	}
+	return deadlineAt(now.Add(seconds(settings.SecondingWindow)))
+}
+
+// openAsync starts debate on a seconded motion straight away when the
+// committee works asynchronously with debate or voting windows, so motions
+// move forward without waiting for the chair. Voting opens when the debate
+// window closes.
+func openAsync(ctx context.Context, motion *models.Motion, settings *models.CommitteeSettings) (*models.Motion, error) {
+	if motion.Status != models.MotionStatusSeconded || settings.Mode != models.CommitteeModeAsync ||
+		(settings.DebateWindow == 0 && settings.VotingWindow == 0) {
+		return motion, nil
+	}
+
+	now := time.Now()
+	extra := bson.M{}
+	votingStarts := now
+	if settings.DebateWindow > 0 && isDebatable(motion) {
+		debateEnds := deadlineAt(now.Add(seconds(settings.DebateWindow)))
+		extra["debate_ends_at"] = debateEnds
+		votingStarts = *debateEnds
+	}
+	if settings.VotingWindow > 0 {
+		extra["voting_ends_at"] = deadlineAt(votingStarts.Add(seconds(settings.VotingWindow)))
+	}
+
+	updated, err := transitionMotion(ctx, motion, models.MotionStatusOpen, extra, nil)
+	if err != nil {
+		return nil, err
+	}
+	deadlinesChanged(updated.ID)
+	return updated, nil
+}
+
+func debateExpired(motion *models.Motion, now time.Time) bool {
+	return motion.DebateEndsAt != nil && !now.Before(*motion.DebateEndsAt)
+}
+
+// debateUnderway reports whether the motion is still inside its debate
+// window, during which votes are not taken.
+func debateUnderway(motion *models.Motion, now time.Time) bool {
+	return motion.DebateEndsAt != nil && !motion.DebateClosed && !debateExpired(motion, now)
+}
+
+// ActiveDeadlines lists the deadlines that still apply to the motion in its
+// current state.
+func ActiveDeadlines(motion *models.Motion) []Deadline {
+	var deadlines []Deadline
+	switch motion.Status {
+	case models.MotionStatusProposed:
+		if motion.SecondingEndsAt != nil {
+			deadlines = append(deadlines, Deadline{models.DeadlineSeconding, *motion.SecondingEndsAt})
+		}
+	case models.MotionStatusOpen:
+		if motion.DebateEndsAt != nil && !motion.DebateClosed {
+			deadlines = append(deadlines, Deadline{models.DeadlineDebate, *motion.DebateEndsAt})
+		}
+		if motion.VotingEndsAt != nil {
+			deadlines = append(deadlines, Deadline{models.DeadlineVoting, *motion.VotingEndsAt})
+		}
+	}
+	return deadlines
+}
+
+// NextDeadlineEvent is when the scheduler next has work for the motion:
+// either a reminder or a deadline.
+func NextDeadlineEvent(motion *models.Motion, settings *models.CommitteeSettings) (time.Time, bool) {
+	var next time.Time
+	found := false
+	consider := func(t time.Time) {
+		if !found || t.Before(next) {
+			next, found = t, true
+		}
+	}
+
+	if motion.EffectPending {
+		consider(motion.UpdatedAt)
+	}
+	for _, deadline := range ActiveDeadlines(motion) {
+		consider(deadline.EndsAt)
+		if settings.ReminderLead > 0 && !reminderSent(motion, deadline.Phase) {
+			consider(deadline.EndsAt.Add(-seconds(settings.ReminderLead)))
+		}
+	}
+	return next, found
+}
+
+func reminderSent(motion *models.Motion, phase models.DeadlinePhase) bool {
+	for _, sent := range motion.RemindersSent {
+		if sent == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// PendingDeadlines loads every motion the scheduler still has to watch. It is
+// how deadlines survive a restart.
+func PendingDeadlines(ctx context.Context) ([]models.Motion, error) {
+	cursor, err := config.GetCollection("motions").Find(ctx, bson.M{"$or": []bson.M{
+		{"status": models.MotionStatusProposed, "seconding_ends_at": bson.M{"$exists": true}},
+		{"status": models.MotionStatusOpen, "debate_ends_at": bson.M{"$exists": true}, "debate_closed": bson.M{"$ne": true}},
+		{"status": models.MotionStatusOpen, "voting_ends_at": bson.M{"$exists": true}},
+		{"effect_pending": true},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	motions := []models.Motion{}
+	if err := cursor.All(ctx, &motions); err != nil {
+		return nil, err
+	}
+	return motions, nil
+}
+
+// ProcessDeadlines sends the reminders that are due and acts on the
+// deadlines that have passed: an unseconded motion expires, debate closes,
+// and voting is counted. It also retries the effect of a passed motion that
+// failed to take effect when voting closed.
+func ProcessDeadlines(ctx context.Context, motionID primitive.ObjectID, now time.Time) (*DeadlineResult, error) {
+	motion, err := GetMotion(ctx, motionID)
+	if err != nil {
+		return nil, err
+	}
+	committee, err := GetCommittee(ctx, motion.CommitteeID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := GetSettings(ctx, motion.CommitteeID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DeadlineResult{Motion: motion}
+	if motion.EffectPending {
+		if err := applyPendingEffect(ctx, motion); err != nil {
+			return nil, err
+		}
+		result.EffectApplied = true
+	}
+	for _, deadline := range ActiveDeadlines(motion) {
+		if now.Before(deadline.EndsAt) {
+			reminder, err := sendReminder(ctx, committee, result.Motion, settings, deadline, now)
+			if err != nil {
+				return nil, err
+			}
+			if reminder != nil {
+				result.Reminders = append(result.Reminders, *reminder)
+			}
+			continue
+		}
+
+		switch deadline.Phase {
+		case models.DeadlineSeconding:
+			updated, err := transitionMotion(ctx, result.Motion, models.MotionStatusExpired, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			result.Motion, result.Expired = updated, true
+		case models.DeadlineDebate:
+			if _, err := config.GetCollection("motions").UpdateOne(ctx,
+				bson.M{"_id": motion.ID, "status": models.MotionStatusOpen},
+				bson.M{"$set": bson.M{"debate_closed": true, "updated_at": now}},
+			); err != nil {
+				return nil, err
+			}
+			result.Motion.DebateClosed, result.DebateClosed = true, true
+		case models.DeadlineVoting:
+			updated, err := closeVoting(ctx, result.Motion, nil)
+			if err != nil {
+				// A higher-ranking question still pending holds the count
+				// back; the scheduler tries again later.
+				if errors.Is(err, ErrHigherMotionPending) || errors.Is(err, ErrParentNotPending) {
+					log.Printf("Voting on motion %s is past its deadline but cannot close yet: %v", motion.ID.Hex(), err)
+					continue
+				}
+				return nil, err
+			}
+			result.Motion, result.VotingClosed = updated, true
+		}
+	}
+	return result, nil
+}
+
+// sendReminder marks a reminder as sent and returns who should get it, or
+// nil when it is not due, was already sent, or nobody still needs to act.
+func sendReminder(ctx context.Context, committee *models.Committee, motion *models.Motion, settings *models.CommitteeSettings, deadline Deadline, now time.Time) (*DeadlineReminder, error) {
+	if settings.ReminderLead == 0 || reminderSent(motion, deadline.Phase) ||
+		now.Before(deadline.EndsAt.Add(-seconds(settings.ReminderLead))) {
+		return nil, nil
+	}
+
+	res, err := config.GetCollection("motions").UpdateOne(ctx,
+		bson.M{"_id": motion.ID, "reminders_sent": bson.M{"$ne": deadline.Phase}},
+		bson.M{"$addToSet": bson.M{"reminders_sent": deadline.Phase}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	motion.RemindersSent = append(motion.RemindersSent, deadline.Phase)
+	if res.ModifiedCount == 0 {
+		return nil, nil
+	}
+
+	skip := map[primitive.ObjectID]bool{}
+	switch deadline.Phase {
+	case models.DeadlineSeconding:
+		skip[motion.MoverID] = true
+	case models.DeadlineVoting:
+		skip, err = voterIDs(ctx, motion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	recipients := []primitive.ObjectID{}
+	for _, memberID := range VotingMemberIDs(committee) {
+		if !skip[memberID] {
+			recipients = append(recipients, memberID)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+	return &DeadlineReminder{Deadline: deadline, Recipients: recipients}, nil
+}
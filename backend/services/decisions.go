@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultSummaryEditWindow = 72 * time.Hour
+
+var (
+	ErrNotDecided       = errors.New("motion has not been decided yet")
+	ErrSummaryLocked    = errors.New("the decision summary can no longer be edited")
+	ErrSummaryRequired  = errors.New("summary rationale is required")
+	ErrInvalidDecisions = errors.New("status must be passed or failed")
+)
+
+var decidedStatuses = []models.MotionStatus{models.MotionStatusPassed, models.MotionStatusFailed}
+
+// DecisionQuery filters the archive, newest first. Before continues a page
+// from the decision time of the last decision seen.
+type DecisionQuery struct {
+	From     *time.Time
+	To       *time.Time
+	Statuses []models.MotionStatus
+	Keyword  string
+	Before   *time.Time
+	Limit    int64
+}
+
+type SummaryInput struct {
+	Rationale string
+	Pros      []string
+	Cons      []string
+}
+
+// SummaryEditWindow reads DECISION_SUMMARY_EDIT_WINDOW as a Go duration
+// ("72h", "30m"), falling back to three days.
+func SummaryEditWindow() time.Duration {
+	if value := os.Getenv("DECISION_SUMMARY_EDIT_WINDOW"); value != "" {
+		if window, err := time.ParseDuration(value); err == nil && window > 0 {
+			return window
+		}
+	}
+	return defaultSummaryEditWindow
+}
+
+func IsDecided(motion *models.Motion) bool {
+	for _, status := range decidedStatuses {
+		if motion.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+func ListDecisions(ctx context.Context, committeeID primitive.ObjectID, query DecisionQuery) ([]models.Decision, error) {
+	statuses := decidedStatuses
+	if len(query.Statuses) > 0 {
+		for _, status := range query.Statuses {
+			if status != models.MotionStatusPassed && status != models.MotionStatusFailed {
+				return nil, ErrInvalidDecisions
+			}
+		}
+		statuses = query.Statuses
+	}
+
+	filter := bson.M{
+		"committee_id": committeeID,
+		"status":       bson.M{"$in": statuses},
+	}
+
+	decidedAt := bson.M{}
+	if query.From != nil {
+		decidedAt["$gte"] = *query.From
+	}
+	if query.To != nil {
+		decidedAt["$lte"] = *query.To
+	}
+	if query.Before != nil {
+		decidedAt["$lt"] = *query.Before
+	}
+	if len(decidedAt) > 0 {
+		filter["tally.decided_at"] = decidedAt
+	}
+
+	if keyword := strings.TrimSpace(query.Keyword); keyword != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(keyword), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"title": pattern},
+			bson.M{"description": pattern},
+			bson.M{"summary.rationale": pattern},
+			bson.M{"summary.pros": pattern},
+			bson.M{"summary.cons": pattern},
+		}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "tally.decided_at", Value: -1}}).SetLimit(query.Limit)
+	cursor, err := config.GetCollection("motions").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var motions []models.Motion
+	if err := cursor.All(ctx, &motions); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(motions))
+	for i, motion := range motions {
+		ids[i] = motion.ID
+	}
+	counts, err := stanceCountsByMotion(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := make([]models.Decision, 0, len(motions))
+	for _, motion := range motions {
+		decisions = append(decisions, models.Decision{Motion: motion, StanceCounts: counts[motion.ID]})
+	}
+	return decisions, nil
+}
+
+// GetDecision returns the full record: the motion with its tally and
+// summary, the threaded debate, and the named votes for roll-call motions.
+func GetDecision(ctx context.Context, motion *models.Motion) (*models.Decision, error) {
+	if !IsDecided(motion) {
+		return nil, ErrNotDecided
+	}
+
+	comments, err := ListComments(ctx, motion.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := &models.Decision{
+		Motion:       *motion,
+		StanceCounts: CountStances(comments),
+		Transcript:   BuildCommentThreads(comments),
+	}
+
+	if !IsSecretBallot(motion) {
+		votes, err := GetVotes(ctx, motion.ID)
+		if err != nil {
+			return nil, err
+		}
+		decision.Votes = votes
+	}
+	return decision, nil
+}
+
+func WriteDecisionSummary(ctx context.Context, motion *models.Motion, userID primitive.ObjectID, input SummaryInput) (*models.Motion, error) {
+	if _, _, err := Authorize(ctx, motion.CommitteeID, userID, CapPreside); err != nil {
+		return nil, err
+	}
+	if !IsDecided(motion) {
+		return nil, ErrNotDecided
+	}
+
+	rationale := strings.TrimSpace(input.Rationale)
+	if rationale == "" {
+		return nil, ErrSummaryRequired
+	}
+
+	now := time.Now()
+	summary := models.DecisionSummary{
+		Rationale: rationale,
+		Pros:      cleanPoints(input.Pros),
+		Cons:      cleanPoints(input.Cons),
+		AuthorID:  userID,
+		WrittenAt: now,
+		UpdatedAt: now,
+		LocksAt:   now.Add(SummaryEditWindow()),
+	}
+
+	filter := bson.M{"_id": motion.ID, "summary": bson.M{"$exists": false}}
+	if motion.Summary != nil {
+		if !now.Before(motion.Summary.LocksAt) {
+			return nil, ErrSummaryLocked
+		}
+		summary.WrittenAt = motion.Summary.WrittenAt
+		summary.LocksAt = motion.Summary.LocksAt
+		filter = bson.M{"_id": motion.ID, "summary.locks_at": bson.M{"$gt": now}}
+	}
+
+	var updated models.Motion
+	err := config.GetCollection("motions").FindOneAndUpdate(ctx,
+		filter,
+		bson.M{"$set": bson.M{"summary": summary, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSummaryLocked
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func cleanPoints(points []string) []string {
+	cleaned := []string{}
+	for _, point := range points {
+		if trimmed := strings.TrimSpace(point); trimmed != "" {
+			cleaned = append(cleaned, trimmed)
+		}
+	}
+	return cleaned
+}
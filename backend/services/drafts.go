@@ -0,0 +1,570 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrMinutesNotFound     = errors.New("minutes not found")
+	ErrMinutesExist        = errors.New("minutes have already been drafted for this meeting")
+	ErrMinutesContent      = errors.New("minutes cannot be empty")
+	ErrMinutesApproved     = errors.New("approved minutes cannot be changed")
+	ErrMinutesChanged      = errors.New("the minutes have changed since the version being edited")
+	ErrMinutesRequired     = errors.New("a motion to approve minutes must name the minutes")
+	ErrApprovalPending     = errors.New("a motion to approve these minutes is pending")
+	ErrCorrectionNotFound  = errors.New("correction not found")
+	ErrCorrectionText      = errors.New("a correction must quote the passage to change")
+	ErrCorrectionResolved  = errors.New("this correction has already been resolved")
+	ErrCorrectionNotInText = errors.New("the passage to correct is not in the current minutes")
+)
+
+func GetMinutesDraft(ctx context.Context, minutesID primitive.ObjectID) (*models.MinutesDraft, error) {
+	var draft models.MinutesDraft
+	err := config.GetCollection("minutes").FindOne(ctx, bson.M{"_id": minutesID}).Decode(&draft)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrMinutesNotFound
+		}
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// ListMinutesDrafts returns a committee's minutes, newest first, without
+// their revision history.
+func ListMinutesDrafts(ctx context.Context, committeeID primitive.ObjectID, statuses []models.MinutesStatus) ([]models.MinutesDraft, error) {
+	filter := bson.M{"committee_id": committeeID}
+	if len(statuses) > 0 {
+		filter["status"] = bson.M{"$in": statuses}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "from", Value: -1}}).
+		SetProjection(bson.M{"history": 0, "record": 0})
+	cursor, err := config.GetCollection("minutes").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	drafts := []models.MinutesDraft{}
+	if err := cursor.All(ctx, &drafts); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// EnsureMinutesIndexes allows a meeting only one set of minutes, so two
+// secretaries drafting at once cannot both succeed. Minutes for a date range
+// have no meeting and are not limited.
+func EnsureMinutesIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("minutes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "meeting_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"meeting_id": bson.M{"$exists": true},
+		}),
+	})
+	return err
+}
+
+// DraftMinutes generates minutes and stores them as version 1 of an editable
+// draft. A meeting only ever has one set of minutes.
+func DraftMinutes(ctx context.Context, committeeID, userID primitive.ObjectID, query MinutesQuery) (*models.MinutesDraft, error) {
+	if _, _, err := Authorize(ctx, committeeID, userID, CapEditMinutes); err != nil {
+		return nil, err
+	}
+
+	collection := config.GetCollection("minutes")
+	if query.MeetingID != nil {
+		count, err := collection.CountDocuments(ctx, bson.M{"meeting_id": *query.MeetingID})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrMinutesExist
+		}
+	}
+
+	record, err := GenerateMinutes(ctx, committeeID, userID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	content := MinutesMarkdown(record)
+	draft := models.MinutesDraft{
+		ID:          primitive.NewObjectID(),
+		CommitteeID: committeeID,
+		MeetingID:   record.MeetingID,
+		Title:       record.Title,
+		From:        record.From,
+		To:          record.To,
+		Status:      models.MinutesStatusDraft,
+		Version:     1,
+		Content:     content,
+		Record:      *record,
+		History: []models.MinutesRevision{{
+			Version:  1,
+			Content:  content,
+			Note:     "Generated",
+			EditedBy: userID,
+			EditedAt: now,
+		}},
+		CreatedBy: userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	_, err = collection.InsertOne(ctx, draft)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrMinutesExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := AuditCommittee(ctx, committeeID, models.AuditMinutesDrafted, &userID, &draft.ID, map[string]string{
+		"title":        draft.Title,
+		"content_hash": contentHash(content),
+	}); err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// EditMinutes saves new text as the next version. baseVersion is the version
+// the editor started from, so two editors cannot silently overwrite each other.
+func EditMinutes(ctx context.Context, minutesID, userID primitive.ObjectID, content, note string, baseVersion int) (*models.MinutesDraft, error) {
+	draft, err := editableDraft(ctx, minutesID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if baseVersion != draft.Version {
+		return nil, ErrMinutesChanged
+	}
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrMinutesContent
+	}
+
+	return saveRevision(ctx, draft, models.MinutesRevision{
+		Content:  content,
+		Note:     strings.TrimSpace(note),
+		EditedBy: userID,
+	})
+}
+
+// editableDraft loads a draft the user may change: they keep the minutes,
+// the draft is not approved, and no motion to approve it is pending.
+func editableDraft(ctx context.Context, minutesID, userID primitive.ObjectID) (*models.MinutesDraft, error) {
+	draft, err := GetMinutesDraft(ctx, minutesID)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := Authorize(ctx, draft.CommitteeID, userID, CapEditMinutes); err != nil {
+		return nil, err
+	}
+	if err := requireUnlocked(ctx, draft); err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// saveRevision appends the next version, failing if anyone else saved one in
+// the meantime or the draft was locked for approval.
+func saveRevision(ctx context.Context, draft *models.MinutesDraft, revision models.MinutesRevision) (*models.MinutesDraft, error) {
+	revision.Version = draft.Version + 1
+	revision.Diff = utils.DiffWords(draft.Content, revision.Content)
+	revision.EditedAt = time.Now()
+
+	var updated models.MinutesDraft
+	err := config.GetCollection("minutes").FindOneAndUpdate(ctx,
+		bson.M{"_id": draft.ID, "status": models.MinutesStatusDraft, "version": draft.Version},
+		bson.M{
+			"$set": bson.M{
+				"content":    revision.Content,
+				"version":    revision.Version,
+				"updated_at": revision.EditedAt,
+			},
+			"$push": bson.M{"history": revision},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		current, err := GetMinutesDraft(ctx, draft.ID)
+		if err != nil {
+			return nil, err
+		}
+		switch current.Status {
+		case models.MinutesStatusApproved:
+			return nil, ErrMinutesApproved
+		case models.MinutesStatusPendingApproval:
+			return nil, ErrApprovalPending
+		}
+		return nil, ErrMinutesChanged
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]string{
+		"version":      fmt.Sprint(revision.Version),
+		"content_hash": contentHash(revision.Content),
+	}
+	if revision.CorrectionID != nil {
+		details["correction_id"] = revision.CorrectionID.Hex()
+	}
+	if err := AuditCommittee(ctx, updated.CommitteeID, models.AuditMinutesRevised, &revision.EditedBy, &updated.ID, details); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// contentHash lets the audit log vouch for the exact text of each version
+// without copying it.
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func ListCorrections(ctx context.Context, minutesID primitive.ObjectID) ([]models.MinutesCorrection, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := config.GetCollection("minutes_corrections").Find(ctx, bson.M{"minutes_id": minutesID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	corrections := []models.MinutesCorrection{}
+	if err := cursor.All(ctx, &corrections); err != nil {
+		return nil, err
+	}
+	return corrections, nil
+}
+
+// ProposeCorrection lets any voting member ask for a passage of the draft to
+// be changed. The secretary or chair then accepts or rejects it.
+func ProposeCorrection(ctx context.Context, minutesID, userID primitive.ObjectID, original, replacement, reason string) (*models.MinutesCorrection, error) {
+	draft, err := GetMinutesDraft(ctx, minutesID)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := Authorize(ctx, draft.CommitteeID, userID, CapDebate); err != nil {
+		return nil, err
+	}
+	// Corrections may still be offered while approval is pending; accepting
+	// one waits until the motion is decided.
+	if draft.Status == models.MinutesStatusApproved {
+		return nil, ErrMinutesApproved
+	}
+
+	if strings.TrimSpace(original) == "" {
+		return nil, ErrCorrectionText
+	}
+	if !strings.Contains(draft.Content, original) {
+		return nil, ErrCorrectionNotInText
+	}
+
+	correction := models.MinutesCorrection{
+		ID:          primitive.NewObjectID(),
+		MinutesID:   draft.ID,
+		ProposedBy:  userID,
+		Version:     draft.Version,
+		Original:    original,
+		Replacement: replacement,
+		Reason:      strings.TrimSpace(reason),
+		Status:      models.CorrectionPending,
+		CreatedAt:   time.Now(),
+	}
+	if _, err := config.GetCollection("minutes_corrections").InsertOne(ctx, correction); err != nil {
+		return nil, err
+	}
+	if err := AuditCommittee(ctx, draft.CommitteeID, models.AuditCorrectionProposed, &userID, &draft.ID, map[string]string{
+		"correction_id": correction.ID.Hex(),
+		"original":      correction.Original,
+		"replacement":   correction.Replacement,
+	}); err != nil {
+		return nil, err
+	}
+	return &correction, nil
+}
+
+// ResolveCorrection accepts or rejects a pending correction. Accepting it
+// replaces the first occurrence of the quoted passage and saves the result as
+// a new version.
+func ResolveCorrection(ctx context.Context, minutesID, correctionID, userID primitive.ObjectID, accept bool) (*models.MinutesDraft, *models.MinutesCorrection, error) {
+	var draft *models.MinutesDraft
+	var err error
+	if accept {
+		draft, err = editableDraft(ctx, minutesID, userID)
+	} else {
+		draft, err = GetMinutesDraft(ctx, minutesID)
+		if err == nil {
+			_, _, err = Authorize(ctx, draft.CommitteeID, userID, CapEditMinutes)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	collection := config.GetCollection("minutes_corrections")
+	var correction models.MinutesCorrection
+	err = collection.FindOne(ctx, bson.M{"_id": correctionID, "minutes_id": draft.ID}).Decode(&correction)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, ErrCorrectionNotFound
+		}
+		return nil, nil, err
+	}
+	if correction.Status != models.CorrectionPending {
+		return nil, nil, ErrCorrectionResolved
+	}
+
+	status := models.CorrectionRejected
+	if accept {
+		if !strings.Contains(draft.Content, correction.Original) {
+			return nil, nil, ErrCorrectionNotInText
+		}
+		status = models.CorrectionAccepted
+	}
+
+	// Claim the correction first so two reviewers cannot both apply it.
+	now := time.Now()
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": correction.ID, "status": models.CorrectionPending},
+		bson.M{"$set": bson.M{"status": status, "resolved_by": userID, "resolved_at": now}},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.ModifiedCount == 0 {
+		return nil, nil, ErrCorrectionResolved
+	}
+	correction.Status = status
+	correction.ResolvedBy = &userID
+	correction.ResolvedAt = &now
+
+	if !accept {
+		if err := auditCorrection(ctx, draft, &correction); err != nil {
+			return nil, nil, err
+		}
+		return draft, &correction, nil
+	}
+
+	note := "Correction"
+	if correction.Reason != "" {
+		note += ": " + correction.Reason
+	}
+	updated, err := saveRevision(ctx, draft, models.MinutesRevision{
+		Content:      strings.Replace(draft.Content, correction.Original, correction.Replacement, 1),
+		Note:         note,
+		CorrectionID: &correction.ID,
+		EditedBy:     userID,
+	})
+	if err != nil {
+		collection.UpdateOne(ctx, bson.M{"_id": correction.ID}, bson.M{
+			"$set":   bson.M{"status": models.CorrectionPending},
+			"$unset": bson.M{"resolved_by": "", "resolved_at": ""},
+		})
+		return nil, nil, err
+	}
+	if err := auditCorrection(ctx, updated, &correction); err != nil {
+		return nil, nil, err
+	}
+	return updated, &correction, nil
+}
+
+func auditCorrection(ctx context.Context, draft *models.MinutesDraft, correction *models.MinutesCorrection) error {
+	return AuditCommittee(ctx, draft.CommitteeID, models.AuditCorrectionResolved, correction.ResolvedBy, &draft.ID, map[string]string{
+		"correction_id": correction.ID.Hex(),
+		"status":        string(correction.Status),
+	})
+}
+
+// approvalLockStatuses are the states of a motion to approve minutes that
+// keep its draft locked: pending, or laid aside and able to be taken up
+// again.
+var approvalLockStatuses = map[models.MotionStatus]bool{
+	models.MotionStatusProposed:  true,
+	models.MotionStatusSeconded:  true,
+	models.MotionStatusOpen:      true,
+	models.MotionStatusTabled:    true,
+	models.MotionStatusPostponed: true,
+	models.MotionStatusReferred:  true,
+}
+
+// requireUnlocked fails unless the draft can still be changed. A lock left
+// behind by an approval motion that has since been decided or withdrawn is
+// released here, so a lost release cannot freeze the draft for good.
+func requireUnlocked(ctx context.Context, draft *models.MinutesDraft) error {
+	switch draft.Status {
+	case models.MinutesStatusDraft:
+		return nil
+	case models.MinutesStatusApproved:
+		return ErrMinutesApproved
+	}
+
+	if draft.ApprovalMotionID == nil {
+		return ErrApprovalPending
+	}
+	motion, err := GetMotion(ctx, *draft.ApprovalMotionID)
+	if errors.Is(err, ErrMotionNotFound) {
+		// The motion is inserted just after the draft is locked.
+		return ErrApprovalPending
+	}
+	if err != nil {
+		return err
+	}
+	if approvalLockStatuses[motion.Status] {
+		return ErrApprovalPending
+	}
+
+	released, err := releaseMinutes(ctx, motion)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return ErrMinutesChanged
+	}
+	draft.Status = models.MinutesStatusDraft
+	draft.ApprovalMotionID = nil
+	return nil
+}
+
+// lockMinutes marks the draft as awaiting approval by the motion, provided
+// it is still the version the motion names. Edits and accepted corrections
+// are refused until the lock is released.
+func lockMinutes(ctx context.Context, draft *models.MinutesDraft, motionID primitive.ObjectID) error {
+	result, err := config.GetCollection("minutes").UpdateOne(ctx,
+		bson.M{"_id": draft.ID, "status": models.MinutesStatusDraft, "version": draft.Version},
+		bson.M{"$set": bson.M{
+			"status":             models.MinutesStatusPendingApproval,
+			"approval_motion_id": motionID,
+			"updated_at":         time.Now(),
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	current, err := GetMinutesDraft(ctx, draft.ID)
+	if err != nil {
+		return err
+	}
+	switch current.Status {
+	case models.MinutesStatusApproved:
+		return ErrMinutesApproved
+	case models.MinutesStatusPendingApproval:
+		return ErrApprovalPending
+	}
+	return ErrMinutesChanged
+}
+
+// releaseMinutes returns a draft the motion locked to editing. It reports
+// false when the motion no longer holds the lock.
+func releaseMinutes(ctx context.Context, motion *models.Motion) (bool, error) {
+	if motion.MinutesID == nil {
+		return false, nil
+	}
+	result, err := config.GetCollection("minutes").UpdateOne(ctx,
+		bson.M{
+			"_id":                *motion.MinutesID,
+			"status":             models.MinutesStatusPendingApproval,
+			"approval_motion_id": motion.ID,
+		},
+		bson.M{
+			"$set":   bson.M{"status": models.MinutesStatusDraft, "updated_at": time.Now()},
+			"$unset": bson.M{"approval_motion_id": ""},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// unlockMinutes hands the draft back for editing once a motion to approve
+// it fails, expires or is withdrawn. Should this release be lost, the next
+// edit releases it instead.
+func unlockMinutes(ctx context.Context, motion *models.Motion) {
+	if motion.Kind != models.MotionKindApproveMinutes || motion.Status == models.MotionStatusPassed ||
+		approvalLockStatuses[motion.Status] {
+		return
+	}
+	if _, err := releaseMinutes(ctx, motion); err != nil {
+		log.Printf("Error releasing minutes locked by motion %s: %v", motion.ID.Hex(), err)
+	}
+}
+
+// checkMinutesApproval validates the draft a motion to approve minutes names.
+// The motion approves the version current when it was made; ProposeMotion
+// locks the draft against edits until the motion is decided.
+func checkMinutesApproval(ctx context.Context, committee *models.Committee, minutesID *primitive.ObjectID) (*models.MinutesDraft, error) {
+	if minutesID == nil {
+		return nil, ErrMinutesRequired
+	}
+
+	draft, err := GetMinutesDraft(ctx, *minutesID)
+	if err != nil {
+		return nil, err
+	}
+	if draft.CommitteeID != committee.ID {
+		return nil, ErrMinutesNotFound
+	}
+	if err := requireUnlocked(ctx, draft); err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// approveMinutes freezes the draft at the version the adopted motion named.
+func approveMinutes(ctx context.Context, motion *models.Motion) error {
+	if motion.MinutesID == nil {
+		return nil
+	}
+
+	now := time.Now()
+	result, err := config.GetCollection("minutes").UpdateOne(ctx,
+		bson.M{
+			"_id":                *motion.MinutesID,
+			"status":             models.MinutesStatusPendingApproval,
+			"approval_motion_id": motion.ID,
+			"version":            motion.MinutesVersion,
+		},
+		bson.M{"$set": bson.M{
+			"status":             models.MinutesStatusApproved,
+			"approval_motion_id": motion.ID,
+			"approved_at":        now,
+			"updated_at":         now,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrMinutesChanged
+	}
+
+	return AuditCommittee(ctx, motion.CommitteeID, models.AuditMinutesApproved, nil, motion.MinutesID, map[string]string{
+		"motion_id": motion.ID.Hex(),
+		"version":   fmt.Sprint(motion.MinutesVersion),
+	})
+}
@@ -0,0 +1,66 @@
+package services
+
+import "errors"
+
+// ErrorClass groups service errors by what the caller did wrong, so the HTTP
+// handlers and the WebSocket protocol report them the same way.
+type ErrorClass string
+
+const (
+	ErrorNotFound   ErrorClass = "not_found"
+	ErrorPermission ErrorClass = "permission"
+	ErrorValidation ErrorClass = "validation"
+	ErrorConflict   ErrorClass = "conflict"
+	ErrorInternal   ErrorClass = "internal"
+)
+
+var errorClasses = []struct {
+	class  ErrorClass
+	errors []error
+}{
+	{ErrorNotFound, []error{
+		ErrCommitteeNotFound, ErrMotionNotFound, ErrCommentNotFound, ErrMeetingNotFound,
+		ErrMinutesNotFound, ErrCorrectionNotFound,
+	}},
+	{ErrorPermission, []error{
+		ErrNotCommitteeMember, ErrPermissionDenied, ErrCannotSecondOwnMotion, ErrNotMotionMover,
+		ErrNotCommentAuthor, ErrNotSpeaker, ErrNotPrevailingVoter, ErrChairCannotAppeal,
+	}},
+	{ErrorValidation, []error{
+		ErrMotionTitleRequired, ErrInvalidVote, ErrInvalidDeadline, ErrInvalidThreshold,
+		ErrInvalidVoteMode, ErrInvalidStance, ErrStanceRequired, ErrCommentContent,
+		ErrParentCommentMotion, ErrSummaryRequired, ErrInvalidDecisions, ErrInvalidMotionKind,
+		ErrRefMotionRequired, ErrParentMotionRequired, ErrInvalidParentMotion, ErrAmendmentTextRequired,
+		ErrInvalidPostponement, ErrInvalidRuling, ErrNoRulingToAppeal, ErrMeetingTitleRequired,
+		ErrAgendaMotion, ErrInvalidQuorum, ErrNoSettingsChange, ErrInvalidMode,
+		ErrInvalidTimeLimit, ErrSettingsChangeRequired, ErrMinutesRange, ErrInvalidMinutesFormat,
+		ErrInvalidWindow, ErrMinutesContent, ErrMinutesRequired, ErrCorrectionText,
+		ErrMotionRequired, ErrNoMotionToSuspend, ErrInvalidSuspendRule,
+	}},
+	{ErrorConflict, []error{
+		ErrCommitteeArchived, ErrInvalidTransition, ErrVotingClosed, ErrResultRequiresTally,
+		ErrAlreadyVoted, ErrDebateLocked, ErrFloorClosed, ErrNotInQueue,
+		ErrQueueEmpty, ErrNoSpeaker, ErrNotDecided, ErrSummaryLocked,
+		ErrRefMotionNotPassed, ErrAlreadyOverturned, ErrOverturnPending, ErrSecretBallotRecords,
+		ErrParentNotPending, ErrOutOfOrder, ErrHigherMotionPending, ErrNotAmendable,
+		ErrSecondNotRequired, ErrNotDebatable, ErrNotChairRuled, ErrAppealPending,
+		ErrMeetingInProgress, ErrMeetingState, ErrAgendaComplete, ErrNoQuorum,
+		ErrMeetingAdjourned, ErrNotCheckedIn, ErrNotInSession, ErrVotingNotStarted,
+		ErrMinutesExist, ErrMinutesApproved, ErrMinutesChanged, ErrApprovalPending,
+		ErrCorrectionResolved, ErrCorrectionNotInText, ErrQueueChanged,
+		ErrRuleDoesNotApply,
+	}},
+}
+
+// ClassifyError reports which class a service error belongs to. Anything it
+// does not recognize is internal and should not be shown to the caller.
+func ClassifyError(err error) ErrorClass {
+	for _, group := range errorClasses {
+		for _, target := range group.errors {
+			if errors.Is(err, target) {
+				return group.class
+			}
+		}
+	}
+	return ErrorInternal
+}
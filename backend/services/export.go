@@ -0,0 +1,416 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/utils"
+)
+
+const minutesTimeLayout = "Jan 2, 2006 15:04 MST"
+
+// RenderMinutes writes the minutes in the requested format and returns the
+// content type and file extension to serve them with.
+func RenderMinutes(minutes *models.Minutes, format models.MinutesFormat) ([]byte, string, string, error) {
+	switch format {
+	case models.MinutesFormatJSON:
+		data, err := json.MarshalIndent(minutes, "", "  ")
+		return data, "application/json", "json", err
+	case models.MinutesFormatMarkdown:
+		return []byte(MinutesMarkdown(minutes)), "text/markdown; charset=utf-8", "md", nil
+	case models.MinutesFormatHTML:
+		data, err := MinutesHTML(minutes)
+		return data, "text/html; charset=utf-8", "html", err
+	case models.MinutesFormatPDF:
+		return MinutesPDF(minutes), "application/pdf", "pdf", nil
+	}
+	return nil, "", "", ErrInvalidMinutesFormat
+}
+
+func MinutesFilename(minutes *models.Minutes, extension string) string {
+	words := strings.FieldsFunc(strings.ToLower(minutes.CommitteeName), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	name := strings.Join(append([]string{"minutes"}, words...), "-")
+	return fmt.Sprintf("%s-%s.%s", name, minutes.From.UTC().Format("2006-01-02"), extension)
+}
+
+// The helpers below phrase each part of the minutes once so every format
+// reads the same.
+
+func formatMinutesTime(t time.Time) string {
+	return t.UTC().Format(minutesTimeLayout)
+}
+
+func minutesPeriod(minutes *models.Minutes) string {
+	return formatMinutesTime(minutes.From) + " to " + formatMinutesTime(minutes.To)
+}
+
+func kindLabel(kind models.MotionKind) string {
+	if kind == models.MotionKindMain {
+		return "Main motion"
+	}
+	label := strings.ReplaceAll(string(kind), "_", " ")
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+func attendeeLine(attendee models.MinutesAttendee) string {
+	line := fmt.Sprintf("%s (%s)", attendee.Name, RoleLabel(attendee.Role))
+	if attendee.CheckedInAt != nil {
+		line += ", arrived " + formatMinutesTime(*attendee.CheckedInAt)
+	}
+	if attendee.CheckedOutAt != nil {
+		line += ", left " + formatMinutesTime(*attendee.CheckedOutAt)
+	}
+	return line
+}
+
+func absentLine(minutes *models.Minutes) string {
+	names := make([]string, len(minutes.Absent))
+	for i, person := range minutes.Absent {
+		names[i] = person.Name
+	}
+	return "Absent: " + strings.Join(names, ", ")
+}
+
+func movedLine(motion models.MinutesMotion) string {
+	line := fmt.Sprintf("Moved by %s on %s", motion.Mover.Name, formatMinutesTime(motion.ProposedAt))
+	if motion.Seconder != nil {
+		return line + "; seconded by " + motion.Seconder.Name + "."
+	}
+	return line + "; not seconded."
+}
+
+func debateLine(debate models.MinutesDebate) string {
+	if debate.Comments == 0 && debate.Messages == 0 {
+		return "No debate was recorded."
+	}
+	return fmt.Sprintf("%d comments (%d pro, %d con, %d neutral) and %d chat messages.",
+		debate.Comments, debate.StanceCounts.Pro, debate.StanceCounts.Con, debate.StanceCounts.Neutral, debate.Messages)
+}
+
+func pointLine(point models.MinutesPoint) string {
+	return fmt.Sprintf("%s (%s): %s", point.Speaker, point.Stance, strings.Join(strings.Fields(point.Content), " "))
+}
+
+func resultLine(motion models.MinutesMotion) string {
+	if motion.Tally == nil {
+		return "Status: " + string(motion.Status) + "."
+	}
+	line := strings.ToUpper(string(motion.Tally.Result)) + ". " + motion.Tally.Rule + "."
+	if motion.VoteMode == models.VoteModeSecret {
+		line += " Taken by secret ballot."
+	}
+	return line
+}
+
+func rollCallLine(motion models.MinutesMotion) string {
+	groups := map[models.VoteResult][]string{}
+	for _, vote := range motion.RollCall {
+		groups[vote.Result] = append(groups[vote.Result], vote.Name)
+	}
+
+	var parts []string
+	for _, result := range []models.VoteResult{models.VoteAye, models.VoteNay, models.VoteAbstain} {
+		if names := groups[result]; len(names) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", result, strings.Join(names, ", ")))
+		}
+	}
+	return "Roll call. " + strings.Join(parts, "; ") + "."
+}
+
+func rulingLine(ruling *models.ChairRuling) string {
+	line := "The chair ruled the point " + strings.ReplaceAll(string(ruling.Decision), "_", " ") + "."
+	if ruling.Explanation != "" {
+		line += " " + ruling.Explanation
+	}
+	if ruling.OverturnedBy != nil {
+		line += " The ruling was overturned on appeal."
+	}
+	return line
+}
+
+func discussionLine(message models.MinutesMessage) string {
+	return fmt.Sprintf("%s, %s: %s", formatMinutesTime(message.At), message.Sender, strings.Join(strings.Fields(message.Content), " "))
+}
+
+func MinutesMarkdown(minutes *models.Minutes) string {
+	var md strings.Builder
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&md, format+"\n", args...)
+	}
+
+	line("# Minutes of %s", minutes.CommitteeName)
+	line("")
+	line("**%s**  ", minutes.Title)
+	line("%s  ", minutesPeriod(minutes))
+	if minutes.CalledToOrder != nil {
+		line("Called to order %s  ", formatMinutesTime(*minutes.CalledToOrder))
+	}
+	if minutes.Adjourned != nil {
+		line("Adjourned %s  ", formatMinutesTime(*minutes.Adjourned))
+	}
+	line("Chair: %s", minutes.Chair.Name)
+	line("")
+
+	line("## Attendance")
+	line("")
+	if len(minutes.Attendance) == 0 {
+		line("Attendance was not recorded.")
+	}
+	for _, attendee := range minutes.Attendance {
+		line("- %s", attendeeLine(attendee))
+	}
+	if len(minutes.Absent) > 0 {
+		line("")
+		line("%s", absentLine(minutes))
+	}
+	line("")
+
+	if len(minutes.Agenda) > 0 {
+		line("## Agenda")
+		line("")
+		for i, item := range minutes.Agenda {
+			line("%d. %s (%s)", i+1, item.Title, item.Status)
+		}
+		line("")
+	}
+
+	line("## Motions")
+	line("")
+	if len(minutes.Motions) == 0 {
+		line("No motions were considered.")
+		line("")
+	}
+	for i, motion := range minutes.Motions {
+		line("### %d. %s", i+1, motion.Title)
+		line("")
+		line("*%s.* %s", kindLabel(motion.Kind), movedLine(motion))
+		line("")
+		if description := strings.TrimSpace(motion.Description); description != "" {
+			for _, text := range strings.Split(description, "\n") {
+				line("> %s", text)
+			}
+			line("")
+		}
+
+		line("**Debate.** %s", debateLine(motion.Debate))
+		line("")
+		for _, point := range motion.Debate.Points {
+			line("- %s", pointLine(point))
+		}
+		if len(motion.Debate.Points) > 0 {
+			line("")
+		}
+
+		if motion.Ruling != nil {
+			line("**Ruling.** %s", rulingLine(motion.Ruling))
+		} else {
+			line("**Result.** %s", resultLine(motion))
+		}
+		line("")
+		if len(motion.RollCall) > 0 {
+			line("%s", rollCallLine(motion))
+			line("")
+		}
+
+		if summary := motion.Summary; summary != nil {
+			line("**Chair's rationale.** %s", summary.Rationale)
+			line("")
+			for _, pro := range summary.Pros {
+				line("- Pro: %s", pro)
+			}
+			for _, con := range summary.Cons {
+				line("- Con: %s", con)
+			}
+			if len(summary.Pros)+len(summary.Cons) > 0 {
+				line("")
+			}
+		}
+	}
+
+	if len(minutes.Discussion) > 0 {
+		line("## Discussion")
+		line("")
+		for _, message := range minutes.Discussion {
+			line("- %s", discussionLine(message))
+		}
+		line("")
+	}
+
+	line("---")
+	line("")
+	line("*Generated %s*", formatMinutesTime(minutes.GeneratedAt))
+	return md.String()
+}
+
+var minutesTemplate = template.Must(template.New("minutes").Funcs(template.FuncMap{
+	"time":       formatMinutesTime,
+	"period":     minutesPeriod,
+	"kind":       kindLabel,
+	"attendee":   attendeeLine,
+	"absent":     absentLine,
+	"moved":      movedLine,
+	"debate":     debateLine,
+	"point":      pointLine,
+	"result":     resultLine,
+	"rollCall":   rollCallLine,
+	"ruling":     rulingLine,
+	"discussion": discussionLine,
+	"inc":        func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Minutes of {{.CommitteeName}}</title>
+<style>
+body { font-family: Georgia, serif; max-width: 46em; margin: 2em auto; padding: 0 1em; color: #222; line-height: 1.5; }
+h1, h2, h3 { font-family: Helvetica, Arial, sans-serif; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: .2em; margin-top: 2em; }
+blockquote { margin: 0 0 1em; padding-left: 1em; border-left: 3px solid #ccc; color: #555; white-space: pre-line; }
+.meta { color: #555; }
+footer { margin-top: 3em; font-size: .85em; color: #777; }
+</style>
+</head>
+<body>
+<h1>Minutes of {{.CommitteeName}}</h1>
+<p class="meta"><strong>{{.Title}}</strong><br>{{period .}}
+{{- with .CalledToOrder}}<br>Called to order {{time .}}{{end}}
+{{- with .Adjourned}}<br>Adjourned {{time .}}{{end}}<br>Chair: {{.Chair.Name}}</p>
+
+<h2>Attendance</h2>
+{{if .Attendance}}<ul>{{range .Attendance}}<li>{{attendee .}}</li>{{end}}</ul>{{else}}<p>Attendance was not recorded.</p>{{end}}
+{{if .Absent}}<p>{{absent .}}</p>{{end}}
+
+{{if .Agenda}}<h2>Agenda</h2>
+<ol>{{range .Agenda}}<li>{{.Title}} ({{.Status}})</li>{{end}}</ol>{{end}}
+
+<h2>Motions</h2>
+{{if not .Motions}}<p>No motions were considered.</p>{{end}}
+{{range $i, $m := .Motions}}<h3>{{inc $i}}. {{$m.Title}}</h3>
+<p><em>{{kind $m.Kind}}.</em> {{moved $m}}</p>
+{{if $m.Description}}<blockquote>{{$m.Description}}</blockquote>{{end}}
+<p><strong>Debate.</strong> {{debate $m.Debate}}</p>
+{{if $m.Debate.Points}}<ul>{{range $m.Debate.Points}}<li>{{point .}}</li>{{end}}</ul>{{end}}
+{{if $m.Ruling}}<p><strong>Ruling.</strong> {{ruling $m.Ruling}}</p>{{else}}<p><strong>Result.</strong> {{result $m}}</p>{{end}}
+{{if $m.RollCall}}<p>{{rollCall $m}}</p>{{end}}
+{{with $m.Summary}}<p><strong>Chair's rationale.</strong> {{.Rationale}}</p>
+{{if or .Pros .Cons}}<ul>{{range .Pros}}<li>Pro: {{.}}</li>{{end}}{{range .Cons}}<li>Con: {{.}}</li>{{end}}</ul>{{end}}{{end}}
+{{end}}
+{{if .Discussion}}<h2>Discussion</h2>
+<ul>{{range .Discussion}}<li>{{discussion .}}</li>{{end}}</ul>{{end}}
+
+<footer>Generated {{time .GeneratedAt}}</footer>
+</body>
+</html>
+`))
+
+// MinutesHTML renders a standalone page with its styles inlined.
+func MinutesHTML(minutes *models.Minutes) ([]byte, error) {
+	var out bytes.Buffer
+	if err := minutesTemplate.Execute(&out, minutes); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func MinutesPDF(minutes *models.Minutes) []byte {
+	pdf := utils.NewPDF()
+	heading := func(text string) {
+		pdf.Space(10)
+		pdf.Text(text, 14, true)
+		pdf.Space(4)
+	}
+	bullet := func(text string) {
+		pdf.TextIndent("- "+text, 10, false, 12)
+	}
+
+	pdf.Text("Minutes of "+minutes.CommitteeName, 18, true)
+	pdf.Space(4)
+	pdf.Text(minutes.Title, 12, true)
+	pdf.Text(minutesPeriod(minutes), 10, false)
+	if minutes.CalledToOrder != nil {
+		pdf.Text("Called to order "+formatMinutesTime(*minutes.CalledToOrder), 10, false)
+	}
+	if minutes.Adjourned != nil {
+		pdf.Text("Adjourned "+formatMinutesTime(*minutes.Adjourned), 10, false)
+	}
+	pdf.Text("Chair: "+minutes.Chair.Name, 10, false)
+	pdf.Rule()
+
+	heading("Attendance")
+	if len(minutes.Attendance) == 0 {
+		pdf.Text("Attendance was not recorded.", 10, false)
+	}
+	for _, attendee := range minutes.Attendance {
+		bullet(attendeeLine(attendee))
+	}
+	if len(minutes.Absent) > 0 {
+		pdf.Space(4)
+		pdf.Text(absentLine(minutes), 10, false)
+	}
+
+	if len(minutes.Agenda) > 0 {
+		heading("Agenda")
+		for i, item := range minutes.Agenda {
+			pdf.TextIndent(fmt.Sprintf("%d. %s (%s)", i+1, item.Title, item.Status), 10, false, 12)
+		}
+	}
+
+	heading("Motions")
+	if len(minutes.Motions) == 0 {
+		pdf.Text("No motions were considered.", 10, false)
+	}
+	for i, motion := range minutes.Motions {
+		pdf.Space(6)
+		pdf.Text(fmt.Sprintf("%d. %s", i+1, motion.Title), 12, true)
+		pdf.Text(kindLabel(motion.Kind)+". "+movedLine(motion), 10, false)
+		if description := strings.TrimSpace(motion.Description); description != "" {
+			pdf.Space(2)
+			pdf.TextIndent(description, 10, false, 12)
+		}
+
+		pdf.Space(4)
+		pdf.Text("Debate. "+debateLine(motion.Debate), 10, false)
+		for _, point := range motion.Debate.Points {
+			bullet(pointLine(point))
+		}
+
+		pdf.Space(4)
+		if motion.Ruling != nil {
+			pdf.Text("Ruling. "+rulingLine(motion.Ruling), 10, true)
+		} else {
+			pdf.Text("Result. "+resultLine(motion), 10, true)
+		}
+		if len(motion.RollCall) > 0 {
+			pdf.Text(rollCallLine(motion), 10, false)
+		}
+
+		if summary := motion.Summary; summary != nil {
+			pdf.Space(4)
+			pdf.Text("Chair's rationale. "+summary.Rationale, 10, false)
+			for _, pro := range summary.Pros {
+				bullet("Pro: " + pro)
+			}
+			for _, con := range summary.Cons {
+				bullet("Con: " + con)
+			}
+		}
+	}
+
+	if len(minutes.Discussion) > 0 {
+		heading("Discussion")
+		for _, message := range minutes.Discussion {
+			bullet(discussionLine(message))
+		}
+	}
+
+	pdf.Rule()
+	pdf.Text("Generated "+formatMinutesTime(minutes.GeneratedAt), 8, false)
+	return pdf.Bytes()
+}
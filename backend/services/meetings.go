@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrMeetingNotFound      = errors.New("meeting not found")
+	ErrMeetingTitleRequired = errors.New("meeting title is required")
+	ErrMeetingInProgress    = errors.New("another meeting of this committee is already in progress")
+	ErrMeetingState         = errors.New("the meeting cannot do that in its current state")
+	ErrAgendaComplete       = errors.New("there are no more agenda items")
+	ErrAgendaMotion         = errors.New("agenda motions must belong to the committee")
+)
+
+var meetingTransitions = map[models.MeetingStatus][]models.MeetingStatus{
+	models.MeetingStatusScheduled:  {models.MeetingStatusInProgress},
+	models.MeetingStatusInProgress: {models.MeetingStatusRecessed, models.MeetingStatusAdjourned},
+	models.MeetingStatusRecessed:   {models.MeetingStatusInProgress, models.MeetingStatusAdjourned},
+}
+
+type AgendaItemInput struct {
+	Title       string
+	Description string
+	MotionID    *primitive.ObjectID
+}
+
+func canTransitionMeeting(from, to models.MeetingStatus) bool {
+	for _, next := range meetingTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func GetMeeting(ctx context.Context, meetingID primitive.ObjectID) (*models.Meeting, error) {
+	var meeting models.Meeting
+	err := config.GetCollection("meetings").FindOne(ctx, bson.M{"_id": meetingID}).Decode(&meeting)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrMeetingNotFound
+		}
+		return nil, err
+	}
+	return &meeting, nil
+}
+
+func ListMeetings(ctx context.Context, committeeID primitive.ObjectID, statuses []models.MeetingStatus) ([]models.Meeting, error) {
+	filter := bson.M{"committee_id": committeeID}
+	if len(statuses) > 0 {
+		filter["status"] = bson.M{"$in": statuses}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "scheduled_for", Value: -1}})
+	cursor, err := config.GetCollection("meetings").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	meetings := []models.Meeting{}
+	if err := cursor.All(ctx, &meetings); err != nil {
+		return nil, err
+	}
+	return meetings, nil
+}
+
+// ActiveMeeting returns the committee's meeting that is in progress, or nil
+// when the committee is not meeting.
+func ActiveMeeting(ctx context.Context, committeeID primitive.ObjectID) (*models.Meeting, error) {
+	var meeting models.Meeting
+	err := config.GetCollection("meetings").FindOne(ctx, bson.M{
+		"committee_id": committeeID,
+		"status":       models.MeetingStatusInProgress,
+	}).Decode(&meeting)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &meeting, nil
+}
+
+// EnsureMeetingIndexes allows a committee only one meeting in progress, so
+// two meetings called to order at once cannot both start.
+func EnsureMeetingIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("meetings").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "committee_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"status": models.MeetingStatusInProgress,
+		}),
+	})
+	return err
+}
+
+func ScheduleMeeting(ctx context.Context, committeeID, userID primitive.ObjectID, title string, scheduledFor time.Time, agenda []AgendaItemInput) (*models.Meeting, error) {
+	if _, _, err := Authorize(ctx, committeeID, userID, CapPreside); err != nil {
+		return nil, err
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, ErrMeetingTitleRequired
+	}
+
+	items := make([]models.AgendaItem, 0, len(agenda))
+	for _, input := range agenda {
+		if input.MotionID != nil {
+			motion, err := GetMotion(ctx, *input.MotionID)
+			if err != nil {
+				return nil, err
+			}
+			if motion.CommitteeID != committeeID {
+				return nil, ErrAgendaMotion
+			}
+		}
+		items = append(items, models.AgendaItem{
+			ID:          primitive.NewObjectID(),
+			Title:       strings.TrimSpace(input.Title),
+			Description: input.Description,
+			MotionID:    input.MotionID,
+			Status:      models.AgendaItemPending,
+		})
+	}
+
+	now := time.Now()
+	meeting := models.Meeting{
+		ID:           primitive.NewObjectID(),
+		CommitteeID:  committeeID,
+		Title:        title,
+		Status:       models.MeetingStatusScheduled,
+		Agenda:       items,
+		CurrentItem:  -1,
+		Motions:      []primitive.ObjectID{},
+		Attendance:   []models.AttendanceRecord{},
+		ScheduledFor: scheduledFor,
+		CreatedBy:    userID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if _, err := config.GetCollection("meetings").InsertOne(ctx, meeting); err != nil {
+		return nil, err
+	}
+	return &meeting, nil
+}
+
+// CallToOrder opens a scheduled meeting on its first agenda item, or resumes
+// one that is in recess.
+func CallToOrder(ctx context.Context, meetingID, userID primitive.ObjectID) (*models.Meeting, error) {
+	meeting, err := authorizeMeeting(ctx, meetingID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := ActiveMeeting(ctx, meeting.CommitteeID)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil && active.ID != meeting.ID {
+		return nil, ErrMeetingInProgress
+	}
+
+	set := bson.M{}
+	if meeting.Status == models.MeetingStatusScheduled {
+		now := time.Now()
+		set["start_time"] = now
+		if len(meeting.Agenda) > 0 {
+			agenda := append([]models.AgendaItem{}, meeting.Agenda...)
+			first := nextAgendaItem(agenda, 0)
+			if first < len(agenda) {
+				agenda[first].Status = models.AgendaItemCurrent
+				agenda[first].StartedAt = &now
+			}
+			set["agenda"] = agenda
+			set["current_item"] = first
+		}
+	}
+
+	updated, err := transitionMeeting(ctx, meeting, models.MeetingStatusInProgress, set)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrMeetingInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if item := CurrentAgendaItem(updated); item != nil && item.MotionID != nil {
+		if err := associateMotion(ctx, updated, *item.MotionID); err != nil {
+			log.Printf("Error associating motion with meeting: %v", err)
+		}
+	}
+	return updated, nil
+}
+
+func AdvanceAgenda(ctx context.Context, meetingID, userID primitive.ObjectID) (*models.Meeting, error) {
+	meeting, err := authorizeMeeting(ctx, meetingID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if meeting.Status != models.MeetingStatusInProgress {
+		return nil, ErrMeetingState
+	}
+
+	if len(meeting.Agenda) == 0 || meeting.CurrentItem >= len(meeting.Agenda) {
+		return nil, ErrAgendaComplete
+	}
+	now := time.Now()
+	agenda := append([]models.AgendaItem{}, meeting.Agenda...)
+	next := nextAgendaItem(agenda, meeting.CurrentItem+1)
+	if meeting.CurrentItem >= 0 && meeting.CurrentItem < len(agenda) {
+		agenda[meeting.CurrentItem].Status = models.AgendaItemDone
+		agenda[meeting.CurrentItem].CompletedAt = &now
+	}
+	if next < len(agenda) {
+		agenda[next].Status = models.AgendaItemCurrent
+		agenda[next].StartedAt = &now
+	}
+
+	var updated models.Meeting
+	err = config.GetCollection("meetings").FindOneAndUpdate(ctx,
+		bson.M{"_id": meeting.ID, "status": models.MeetingStatusInProgress, "current_item": meeting.CurrentItem},
+		bson.M{"$set": bson.M{"agenda": agenda, "current_item": next, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrMeetingState
+		}
+		return nil, err
+	}
+
+	if item := CurrentAgendaItem(&updated); item != nil && item.MotionID != nil {
+		if err := associateMotion(ctx, &updated, *item.MotionID); err != nil {
+			log.Printf("Error associating motion with meeting: %v", err)
+		}
+	}
+	return &updated, nil
+}
+
+func AdjournMeeting(ctx context.Context, meetingID, userID primitive.ObjectID) (*models.Meeting, error) {
+	meeting, err := authorizeMeeting(ctx, meetingID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return adjournMeeting(ctx, meeting)
+}
+
+// nextAgendaItem skips items that were closed before they came up, such as
+// those for a withdrawn motion.
+func nextAgendaItem(agenda []models.AgendaItem, from int) int {
+	for from < len(agenda) && agenda[from].Status == models.AgendaItemDone {
+		from++
+	}
+	return from
+}
+
+// closeAgendaItems marks every agenda item still waiting on a motion as done,
+// for when the motion is no longer coming before the meeting.
+func closeAgendaItems(ctx context.Context, motionID primitive.ObjectID) error {
+	_, err := config.GetCollection("meetings").UpdateMany(ctx,
+		bson.M{
+			"status":           bson.M{"$ne": models.MeetingStatusAdjourned},
+			"agenda.motion_id": motionID,
+		},
+		bson.M{"$set": bson.M{
+			"agenda.$[item].status":       models.AgendaItemDone,
+			"agenda.$[item].completed_at": time.Now(),
+		}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []any{
+			bson.M{"item.motion_id": motionID, "item.status": models.AgendaItemPending},
+		}}),
+	)
+	return err
+}
+
+func CurrentAgendaItem(meeting *models.Meeting) *models.AgendaItem {
+	if meeting.CurrentItem < 0 || meeting.CurrentItem >= len(meeting.Agenda) {
+		return nil
+	}
+	return &meeting.Agenda[meeting.CurrentItem]
+}
+
+func adjournMeeting(ctx context.Context, meeting *models.Meeting) (*models.Meeting, error) {
+	now := time.Now()
+	set := bson.M{"end_time": now}
+	if item := CurrentAgendaItem(meeting); item != nil {
+		agenda := append([]models.AgendaItem{}, meeting.Agenda...)
+		agenda[meeting.CurrentItem].Status = models.AgendaItemDone
+		agenda[meeting.CurrentItem].CompletedAt = &now
+		set["agenda"] = agenda
+	}
+	return transitionMeeting(ctx, meeting, models.MeetingStatusAdjourned, set)
+}
+
+// applyMeetingMotion carries out an adopted motion to adjourn or recess on
+// the meeting it was made in.
+func applyMeetingMotion(ctx context.Context, motion *models.Motion) (*models.Meeting, error) {
+	if motion.MeetingID == nil {
+		return nil, nil
+	}
+
+	meeting, err := GetMeeting(ctx, *motion.MeetingID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch motion.Kind {
+	case models.MotionKindAdjourn:
+		return adjournMeeting(ctx, meeting)
+	case models.MotionKindRecess:
+		return transitionMeeting(ctx, meeting, models.MeetingStatusRecessed, nil)
+	}
+	return nil, nil
+}
+
+func authorizeMeeting(ctx context.Context, meetingID, userID primitive.ObjectID) (*models.Meeting, error) {
+	meeting, err := GetMeeting(ctx, meetingID)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := Authorize(ctx, meeting.CommitteeID, userID, CapPreside); err != nil {
+		return nil, err
+	}
+	return meeting, nil
+}
+
+func transitionMeeting(ctx context.Context, meeting *models.Meeting, to models.MeetingStatus, extra bson.M) (*models.Meeting, error) {
+	if !canTransitionMeeting(meeting.Status, to) {
+		return nil, ErrMeetingState
+	}
+
+	set := bson.M{
+		"status":     to,
+		"updated_at": time.Now(),
+	}
+	for key, value := range extra {
+		set[key] = value
+	}
+
+	var updated models.Meeting
+	err := config.GetCollection("meetings").FindOneAndUpdate(ctx,
+		bson.M{"_id": meeting.ID, "status": meeting.Status},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrMeetingState
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// associateMotion records that a motion was handled in a meeting. A motion
+// carried over to later meetings points at the most recent one, and every
+// meeting keeps the full list of motions it handled.
+func associateMotion(ctx context.Context, meeting *models.Meeting, motionID primitive.ObjectID) error {
+	if _, err := config.GetCollection("meetings").UpdateOne(ctx,
+		bson.M{"_id": meeting.ID},
+		bson.M{"$addToSet": bson.M{"motions": motionID}},
+	); err != nil {
+		return err
+	}
+
+	_, err := config.GetCollection("motions").UpdateOne(ctx,
+		bson.M{"_id": motionID},
+		bson.M{"$set": bson.M{"meeting_id": meeting.ID}},
+	)
+	return err
+}
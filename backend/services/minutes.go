@@ -0,0 +1,417 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxMinutesMessages = 1000
+
+var (
+	ErrMinutesRange         = errors.New("minutes need a meeting or a from date")
+	ErrInvalidMinutesFormat = errors.New("format must be json, md, html or pdf")
+)
+
+type MinutesQuery struct {
+	MeetingID *primitive.ObjectID
+	From      *time.Time
+	To        *time.Time
+}
+
+func ValidMinutesFormat(format models.MinutesFormat) bool {
+	switch format {
+	case models.MinutesFormatJSON, models.MinutesFormatMarkdown, models.MinutesFormatHTML, models.MinutesFormatPDF:
+		return true
+	}
+	return false
+}
+
+// GenerateMinutes assembles the record of a meeting, or of everything the
+// committee moved or decided in a date range.
+func GenerateMinutes(ctx context.Context, committeeID, userID primitive.ObjectID, query MinutesQuery) (*models.Minutes, error) {
+	committee, _, err := Authorize(ctx, committeeID, userID, CapViewCommittee)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	minutes := &models.Minutes{
+		CommitteeID:   committee.ID,
+		CommitteeName: committee.Name,
+		Attendance:    []models.MinutesAttendee{},
+		Absent:        []models.MinutesPerson{},
+		Motions:       []models.MinutesMotion{},
+		Discussion:    []models.MinutesMessage{},
+		GeneratedAt:   now,
+	}
+
+	var attendance []models.AttendanceRecord
+	var motionFilter bson.M
+	if query.MeetingID != nil {
+		meeting, err := GetMeeting(ctx, *query.MeetingID)
+		if err != nil {
+			return nil, err
+		}
+		if meeting.CommitteeID != committee.ID {
+			return nil, ErrMeetingNotFound
+		}
+
+		minutes.MeetingID = &meeting.ID
+		minutes.Title = meeting.Title
+		minutes.From = meeting.ScheduledFor
+		if meeting.StartTime != nil {
+			minutes.From = *meeting.StartTime
+		}
+		minutes.To = now
+		if meeting.EndTime != nil {
+			minutes.To = *meeting.EndTime
+		}
+		minutes.CalledToOrder = meeting.StartTime
+		minutes.Adjourned = meeting.EndTime
+		minutes.Agenda = meeting.Agenda
+		attendance = meeting.Attendance
+		motionFilter = bson.M{"_id": bson.M{"$in": meeting.Motions}}
+	} else {
+		if query.From == nil {
+			return nil, ErrMinutesRange
+		}
+		minutes.From = *query.From
+		minutes.To = now
+		if query.To != nil {
+			minutes.To = *query.To
+		}
+		minutes.Title = "Committee business"
+
+		attendance, err = rangeAttendance(ctx, committee.ID, minutes.From, minutes.To)
+		if err != nil {
+			return nil, err
+		}
+		window := bson.M{"$gte": minutes.From, "$lte": minutes.To}
+		motionFilter = bson.M{
+			"committee_id": committee.ID,
+			"$or": bson.A{
+				bson.M{"created_at": window},
+				bson.M{"tally.decided_at": window},
+			},
+		}
+	}
+
+	motions, err := findMotions(ctx, motionFilter)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := roomMessages(ctx, committee.ID, minutes.From, minutes.To)
+	if err != nil {
+		return nil, err
+	}
+
+	names := newNameBook()
+	names.add(committee.ChairID)
+	names.add(VotingMemberIDs(committee)...)
+	for _, record := range attendance {
+		names.add(record.UserID)
+	}
+	for _, message := range messages {
+		names.add(message.SenderID)
+	}
+
+	records := make([]motionRecord, 0, len(motions))
+	for i := range motions {
+		record, err := loadMotionRecord(ctx, &motions[i])
+		if err != nil {
+			return nil, err
+		}
+		names.addRecord(record)
+		records = append(records, record)
+	}
+
+	if err := names.load(ctx); err != nil {
+		return nil, err
+	}
+
+	minutes.Chair = names.person(committee.ChairID)
+	present := make(map[primitive.ObjectID]bool, len(attendance))
+	for _, record := range attendance {
+		present[record.UserID] = true
+		minutes.Attendance = append(minutes.Attendance, models.MinutesAttendee{
+			MinutesPerson: names.person(record.UserID),
+			Role:          RoleOf(committee, record.UserID),
+			CheckedInAt:   &record.CheckedInAt,
+			CheckedOutAt:  record.CheckedOutAt,
+		})
+	}
+	if len(attendance) > 0 {
+		for _, memberID := range VotingMemberIDs(committee) {
+			if !present[memberID] {
+				minutes.Absent = append(minutes.Absent, names.person(memberID))
+			}
+		}
+	}
+
+	motionMessages := make(map[primitive.ObjectID]int)
+	for _, message := range messages {
+		if message.MotionID != nil {
+			motionMessages[*message.MotionID]++
+			continue
+		}
+		minutes.Discussion = append(minutes.Discussion, models.MinutesMessage{
+			Sender:  names.name(message.SenderID),
+			Content: message.Content,
+			At:      message.Timestamp,
+		})
+	}
+
+	for _, record := range records {
+		minutes.Motions = append(minutes.Motions, record.minutes(names, motionMessages[record.motion.ID]))
+	}
+	return minutes, nil
+}
+
+// rangeAttendance merges the rolls of every meeting held in the range,
+// keeping each member's first arrival and last departure.
+func rangeAttendance(ctx context.Context, committeeID primitive.ObjectID, from, to time.Time) ([]models.AttendanceRecord, error) {
+	cursor, err := config.GetCollection("meetings").Find(ctx, bson.M{
+		"committee_id": committeeID,
+		"start_time":   bson.M{"$gte": from, "$lte": to},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var meetings []models.Meeting
+	if err := cursor.All(ctx, &meetings); err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[primitive.ObjectID]int)
+	var attendance []models.AttendanceRecord
+	for _, meeting := range meetings {
+		for _, record := range meeting.Attendance {
+			i, ok := byUser[record.UserID]
+			if !ok {
+				byUser[record.UserID] = len(attendance)
+				attendance = append(attendance, record)
+				continue
+			}
+			merged := &attendance[i]
+			if record.CheckedInAt.Before(merged.CheckedInAt) {
+				merged.CheckedInAt = record.CheckedInAt
+			}
+			if merged.CheckedOutAt != nil && (record.CheckedOutAt == nil || record.CheckedOutAt.After(*merged.CheckedOutAt)) {
+				merged.CheckedOutAt = record.CheckedOutAt
+			}
+		}
+	}
+
+	sort.SliceStable(attendance, func(i, j int) bool {
+		return attendance[i].CheckedInAt.Before(attendance[j].CheckedInAt)
+	})
+	return attendance, nil
+}
+
+func findMotions(ctx context.Context, filter bson.M) ([]models.Motion, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := config.GetCollection("motions").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	motions := []models.Motion{}
+	if err := cursor.All(ctx, &motions); err != nil {
+		return nil, err
+	}
+	return motions, nil
+}
+
+func roomMessages(ctx context.Context, committeeID primitive.ObjectID, from, to time.Time) ([]models.Message, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: 1}}).
+		SetLimit(maxMinutesMessages)
+	cursor, err := config.GetCollection("messages").Find(ctx, bson.M{
+		"roomId":    models.CreateCommitteeRoomID(committeeID),
+		"timestamp": bson.M{"$gte": from, "$lte": to},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []models.Message{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+type motionRecord struct {
+	motion   *models.Motion
+	comments []models.Comment
+	votes    []models.Vote
+}
+
+func loadMotionRecord(ctx context.Context, motion *models.Motion) (motionRecord, error) {
+	record := motionRecord{motion: motion}
+
+	comments, err := ListComments(ctx, motion.ID)
+	if err != nil {
+		return record, err
+	}
+	record.comments = comments
+
+	if IsDecided(motion) && !IsSecretBallot(motion) {
+		votes, err := GetVotes(ctx, motion.ID)
+		if err != nil {
+			return record, err
+		}
+		record.votes = votes
+	}
+	return record, nil
+}
+
+// minutes condenses the debate to the opening points on each side; replies
+// are counted but left to the decision archive.
+func (r motionRecord) minutes(names *nameBook, messages int) models.MinutesMotion {
+	motion := r.motion
+	entry := models.MinutesMotion{
+		ID:          motion.ID,
+		Kind:        motion.Kind,
+		Title:       motion.Title,
+		Description: motion.Description,
+		Status:      motion.Status,
+		Mover:       names.person(motion.MoverID),
+		ProposedAt:  motion.CreatedAt,
+		Tally:       motion.Tally,
+		VoteMode:    motion.VoteMode,
+		Ruling:      motion.Ruling,
+		Summary:     motion.Summary,
+		Debate: models.MinutesDebate{
+			StanceCounts: CountStances(r.comments),
+			Messages:     messages,
+			Points:       []models.MinutesPoint{},
+		},
+	}
+	if entry.Kind == "" {
+		entry.Kind = models.MotionKindMain
+	}
+	if motion.SeconderID != nil {
+		seconder := names.person(*motion.SeconderID)
+		entry.Seconder = &seconder
+	}
+
+	for _, comment := range r.comments {
+		if comment.IsDeleted {
+			continue
+		}
+		entry.Debate.Comments++
+		if comment.ParentID == nil {
+			entry.Debate.Points = append(entry.Debate.Points, models.MinutesPoint{
+				Speaker: names.name(comment.UserID),
+				Stance:  comment.Stance,
+				Content: comment.Content,
+				At:      comment.CreatedAt,
+			})
+		}
+	}
+
+	for _, vote := range r.votes {
+		entry.RollCall = append(entry.RollCall, models.MinutesVote{
+			Name:   names.name(vote.UserID),
+			Result: vote.Result,
+		})
+	}
+	return entry
+}
+
+// nameBook collects user IDs while the minutes are assembled and resolves
+// them to display names with a single query.
+type nameBook struct {
+	ids   map[primitive.ObjectID]bool
+	names map[primitive.ObjectID]string
+}
+
+func newNameBook() *nameBook {
+	return &nameBook{
+		ids:   make(map[primitive.ObjectID]bool),
+		names: make(map[primitive.ObjectID]string),
+	}
+}
+
+func (b *nameBook) add(ids ...primitive.ObjectID) {
+	for _, id := range ids {
+		if !id.IsZero() {
+			b.ids[id] = true
+		}
+	}
+}
+
+func (b *nameBook) addRecord(record motionRecord) {
+	b.add(record.motion.MoverID)
+	if record.motion.SeconderID != nil {
+		b.add(*record.motion.SeconderID)
+	}
+	for _, comment := range record.comments {
+		b.add(comment.UserID)
+	}
+	for _, vote := range record.votes {
+		b.add(vote.UserID)
+	}
+}
+
+func (b *nameBook) load(ctx context.Context) error {
+	if len(b.ids) == 0 {
+		return nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(b.ids))
+	for id := range b.ids {
+		ids = append(ids, id)
+	}
+
+	opts := options.Find().SetProjection(bson.M{"name": 1, "givenName": 1, "familyName": 1})
+	cursor, err := config.GetCollection("users").Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return err
+	}
+	for _, user := range users {
+		b.names[user.ID] = displayName(user)
+	}
+	return nil
+}
+
+func (b *nameBook) name(id primitive.ObjectID) string {
+	if name, ok := b.names[id]; ok {
+		return name
+	}
+	return "Unknown member"
+}
+
+func (b *nameBook) person(id primitive.ObjectID) models.MinutesPerson {
+	return models.MinutesPerson{ID: id, Name: b.name(id)}
+}
+
+func displayName(user models.User) string {
+	if name := strings.TrimSpace(user.Name); name != "" {
+		return name
+	}
+	if name := strings.TrimSpace(user.GivenName + " " + user.FamilyName); name != "" {
+		return name
+	}
+	return "Unknown member"
+}
@@ -0,0 +1,513 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrMotionNotFound        = errors.New("motion not found")
+	ErrInvalidTransition     = errors.New("invalid motion status transition")
+	ErrCannotSecondOwnMotion = errors.New("the mover cannot second their own motion")
+	ErrMotionTitleRequired   = errors.New("motion title is required")
+	ErrNotMotionMover        = errors.New("only the mover can change this motion")
+	ErrSecondNotRequired     = errors.New("this motion does not take a second")
+	ErrMotionRequired        = errors.New("the chair cannot make this change alone; it takes an adopted motion")
+)
+
+var motionTransitions = map[models.MotionStatus][]models.MotionStatus{
+	models.MotionStatusProposed: {
+		models.MotionStatusSeconded, models.MotionStatusRuled, models.MotionStatusExpired,
+		models.MotionStatusWithdrawn,
+	},
+	models.MotionStatusSeconded: {models.MotionStatusOpen, models.MotionStatusWithdrawn},
+	models.MotionStatusOpen: {
+		models.MotionStatusPassed, models.MotionStatusFailed, models.MotionStatusTabled,
+		models.MotionStatusPostponed, models.MotionStatusReferred, models.MotionStatusWithdrawn,
+	},
+	models.MotionStatusTabled:    {models.MotionStatusOpen},
+	models.MotionStatusPostponed: {models.MotionStatusOpen},
+	models.MotionStatusReferred:  {models.MotionStatusOpen},
+}
+
+// chairStatuses are the changes the chair may make without a vote: stating
+// the question, or taking up again a motion that was laid aside. Laying a
+// motion aside takes an adopted table, postpone or refer motion.
+var chairStatuses = map[models.MotionStatus]bool{
+	models.MotionStatusOpen: true,
+}
+
+type ProposeMotionInput struct {
+	Title       string
+	Description string
+	IsSpecial   bool
+	Threshold   models.VoteThreshold
+	VoteMode    models.VoteMode
+	Kind        models.MotionKind
+	RefMotionID *primitive.ObjectID
+	SubsidiaryInput
+	SettingsChange *models.SettingsChange
+	MinutesID      *primitive.ObjectID
+	SuspendedRule  models.SuspendedRule
+}
+
+func CanTransition(from, to models.MotionStatus) bool {
+	for _, next := range motionTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func GetMotion(ctx context.Context, motionID primitive.ObjectID) (*models.Motion, error) {
+	var motion models.Motion
+	err := config.GetCollection("motions").FindOne(ctx, bson.M{"_id": motionID}).Decode(&motion)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrMotionNotFound
+		}
+		return nil, err
+	}
+	return &motion, nil
+}
+
+func ListMotions(ctx context.Context, committeeID primitive.ObjectID, statuses []models.MotionStatus) ([]models.Motion, error) {
+	filter := bson.M{"committee_id": committeeID}
+	if len(statuses) > 0 {
+		filter["status"] = bson.M{"$in": statuses}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := config.GetCollection("motions").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	motions := []models.Motion{}
+	if err := cursor.All(ctx, &motions); err != nil {
+		return nil, err
+	}
+	return motions, nil
+}
+
+func ProposeMotion(ctx context.Context, committee *models.Committee, moverID primitive.ObjectID, input ProposeMotionInput) (*models.Motion, error) {
+	if _, err := AuthorizeCommittee(committee, moverID, CapProposeMotion); err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		return nil, ErrMotionTitleRequired
+	}
+
+	kind := input.Kind
+	if kind == "" {
+		kind = models.MotionKindMain
+	}
+	spec, ok := SpecFor(kind)
+	if !ok {
+		return nil, ErrInvalidMotionKind
+	}
+
+	settings, err := GetSettings(ctx, committee.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireSession(ctx, settings); err != nil {
+		return nil, err
+	}
+
+	// Without a quorum the meeting can still recess, adjourn or raise a
+	// question of privilege, but cannot take up new business.
+	if spec.Class != models.MotionClassPrivileged {
+		if err := requireQuorum(ctx, committee); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only main motions choose their threshold; every other kind uses the one
+	// its catalog entry prescribes.
+	threshold := spec.Threshold
+	var refMotionID, parentMotionID *primitive.ObjectID
+	var minutes *models.MinutesDraft
+	switch {
+	case kind == models.MotionKindMain:
+		requested := input.Threshold
+		if requested == "" && !input.IsSpecial {
+			requested = settings.DefaultThreshold
+		}
+		threshold, err = ResolveThreshold(requested, input.IsSpecial)
+		if err != nil {
+			return nil, err
+		}
+	case IsOverturnKind(kind):
+		original, err := checkOverturn(ctx, committee, moverID, input.RefMotionID)
+		if err != nil {
+			return nil, err
+		}
+		threshold, err = overturnThreshold(kind, input.Threshold)
+		if err != nil {
+			return nil, err
+		}
+		refMotionID = &original.ID
+	case kind == models.MotionKindAppeal:
+		ruled, err := checkAppeal(ctx, committee, moverID, input.RefMotionID)
+		if err != nil {
+			return nil, err
+		}
+		refMotionID = &ruled.ID
+	case kind == models.MotionKindSuspendRules:
+		target, err := checkSuspension(ctx, committee, input.RefMotionID, input.SuspendedRule)
+		if err != nil {
+			return nil, err
+		}
+		refMotionID = &target.ID
+	case kind == models.MotionKindProcedure:
+		if input.SettingsChange == nil {
+			return nil, ErrSettingsChangeRequired
+		}
+	case kind == models.MotionKindApproveMinutes:
+		minutes, err = checkMinutesApproval(ctx, committee, input.MinutesID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.Class != models.MotionClassMain && spec.Class != models.MotionClassRestorative {
+		parent, err := checkAttachment(ctx, committee, spec, input.SubsidiaryInput)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			parentMotionID = &parent.ID
+		}
+	}
+
+	status := models.MotionStatusProposed
+	if !requiresSecond(spec, settings) && !spec.ChairRules {
+		status = models.MotionStatusSeconded
+	}
+
+	requestedMode := input.VoteMode
+	if requestedMode == "" && settings.SecretByDefault {
+		requestedMode = models.VoteModeSecret
+	}
+	voteMode, err := ResolveVoteMode(requestedMode)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	motion := models.Motion{
+		ID:          primitive.NewObjectID(),
+		CommitteeID: committee.ID,
+		MoverID:     moverID,
+		Kind:        kind,
+		RefMotionID: refMotionID,
+		Title:       title,
+		Description: input.Description,
+		Status:      status,
+		Votes:       []models.Vote{},
+		Comments:    []models.Comment{},
+		IsSpecial:   input.IsSpecial,
+		Threshold:   threshold,
+		VoteMode:    voteMode,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if status == models.MotionStatusProposed && !spec.ChairRules {
+		motion.SecondingEndsAt = secondingDeadline(settings, now)
+	}
+	if kind == models.MotionKindProcedure {
+		motion.SettingsChange = input.SettingsChange
+	}
+	if kind == models.MotionKindSuspendRules {
+		motion.SuspendedRule = input.SuspendedRule
+	}
+	if minutes != nil {
+		motion.MinutesID = &minutes.ID
+		motion.MinutesVersion = minutes.Version
+	}
+	if parentMotionID != nil {
+		motion.ParentMotionID = parentMotionID
+		switch kind {
+		case models.MotionKindAmend:
+			motion.Amendment = input.Amendment
+		case models.MotionKindPostpone:
+			motion.PostponeUntil = input.PostponeUntil
+		case models.MotionKindRefer:
+			motion.ReferToCommitteeID = input.ReferToCommitteeID
+		}
+	}
+
+	meeting, err := ActiveMeeting(ctx, committee.ID)
+	if err != nil {
+		return nil, err
+	}
+	if meeting != nil {
+		motion.MeetingID = &meeting.ID
+	}
+
+	if minutes != nil {
+		if err := lockMinutes(ctx, minutes, motion.ID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := config.GetCollection("motions").InsertOne(ctx, motion); err != nil {
+		if minutes != nil {
+			releaseMinutes(ctx, &motion)
+		}
+		return nil, err
+	}
+	if err := AuditCommittee(ctx, committee.ID, models.AuditMotionProposed, &moverID, &motion.ID, map[string]string{
+		"kind":      string(motion.Kind),
+		"title":     motion.Title,
+		"threshold": string(motion.Threshold),
+		"vote_mode": string(motion.VoteMode),
+	}); err != nil {
+		return nil, err
+	}
+
+	if meeting != nil {
+		if err := associateMotion(ctx, meeting, motion.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if motion.SecondingEndsAt != nil {
+		deadlinesChanged(motion.ID)
+	}
+	return openAsync(ctx, &motion, settings)
+}
+
+func SecondMotion(ctx context.Context, motionID, userID primitive.ObjectID) (*models.Motion, error) {
+	motion, err := GetMotion(ctx, motionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := Authorize(ctx, motion.CommitteeID, userID, CapSecondMotion); err != nil {
+		return nil, err
+	}
+
+	settings, err := GetSettings(ctx, motion.CommitteeID)
+	if err != nil {
+		return nil, err
+	}
+	if spec, _ := SpecFor(motion.Kind); !requiresSecond(spec, settings) {
+		return nil, ErrSecondNotRequired
+	}
+
+	if motion.MoverID == userID {
+		return nil, ErrCannotSecondOwnMotion
+	}
+
+	seconded, err := transitionMotion(ctx, motion, models.MotionStatusSeconded, bson.M{"seconder_id": userID}, &userID)
+	if err != nil {
+		return nil, err
+	}
+	return openAsync(ctx, seconded, settings)
+}
+
+func ChangeMotionStatus(ctx context.Context, motionID, userID primitive.ObjectID, to models.MotionStatus) (*models.Motion, models.MotionStatus, error) {
+	if to == models.MotionStatusPassed || to == models.MotionStatusFailed {
+		return nil, "", ErrResultRequiresTally
+	}
+	if !chairStatuses[to] {
+		return nil, "", ErrMotionRequired
+	}
+
+	motion, err := GetMotion(ctx, motionID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	committee, _, err := Authorize(ctx, motion.CommitteeID, userID, CapPreside)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if to == models.MotionStatusOpen {
+		if err := requireQuorum(ctx, committee); err != nil {
+			return nil, "", err
+		}
+	}
+
+	updated, err := transitionMotion(ctx, motion, to, nil, &userID)
+	if err != nil {
+		return nil, "", err
+	}
+	return updated, motion.Status, nil
+}
+
+func TransitionMotion(ctx context.Context, motion *models.Motion, to models.MotionStatus) (*models.Motion, error) {
+	return transitionMotion(ctx, motion, to, nil, nil)
+}
+
+// transitionMotion moves a motion between statuses and records the change in
+// the committee's audit log. actorID is nil when the system made the change.
+func transitionMotion(ctx context.Context, motion *models.Motion, to models.MotionStatus, extra bson.M, actorID *primitive.ObjectID) (*models.Motion, error) {
+	if !CanTransition(motion.Status, to) {
+		return nil, ErrInvalidTransition
+	}
+
+	set := bson.M{
+		"status":     to,
+		"updated_at": time.Now(),
+	}
+	for key, value := range extra {
+		set[key] = value
+	}
+
+	var updated models.Motion
+	err := config.GetCollection("motions").FindOneAndUpdate(ctx,
+		bson.M{"_id": motion.ID, "status": motion.Status},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidTransition
+		}
+		return nil, err
+	}
+
+	details := map[string]string{"from": string(motion.Status), "to": string(to)}
+	if tally := updated.Tally; tally != nil && to == tally.Result {
+		details["ayes"] = fmt.Sprint(tally.Ayes)
+		details["nays"] = fmt.Sprint(tally.Nays)
+		details["abstentions"] = fmt.Sprint(tally.Abstentions)
+	}
+	if ruling := updated.Ruling; ruling != nil && to == models.MotionStatusRuled {
+		details["ruling"] = string(ruling.Decision)
+	}
+	unlockMinutes(ctx, &updated)
+
+	if err := AuditCommittee(ctx, updated.CommitteeID, models.AuditMotionStatus, actorID, &updated.ID, details); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func UpdateMotionText(ctx context.Context, motion *models.Motion, userID primitive.ObjectID, title, description *string) (*models.Motion, error) {
+	if motion.MoverID != userID {
+		return nil, ErrNotMotionMover
+	}
+	if motion.Status != models.MotionStatusProposed {
+		return nil, ErrInvalidTransition
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	if title != nil {
+		trimmed := strings.TrimSpace(*title)
+		if trimmed == "" {
+			return nil, ErrMotionTitleRequired
+		}
+		set["title"] = trimmed
+	}
+	if description != nil {
+		set["description"] = *description
+	}
+
+	var updated models.Motion
+	err := config.GetCollection("motions").FindOneAndUpdate(ctx,
+		bson.M{"_id": motion.ID, "status": models.MotionStatusProposed},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidTransition
+		}
+		return nil, err
+	}
+
+	details := map[string]string{"title": updated.Title}
+	if description != nil {
+		details["description"] = updated.Description
+	}
+	if err := AuditCommittee(ctx, updated.CommitteeID, models.AuditMotionEdited, &userID, &updated.ID, details); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// WithdrawMotion lets the mover take back a motion before the chair states
+// it. The motion is kept, marked withdrawn, and anything that depended on it
+// is withdrawn or closed with it.
+func WithdrawMotion(ctx context.Context, motion *models.Motion, userID primitive.ObjectID) (*models.Motion, error) {
+	if motion.MoverID != userID {
+		return nil, ErrNotMotionMover
+	}
+
+	var updated models.Motion
+	err := config.GetCollection("motions").FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":    motion.ID,
+			"status": bson.M{"$in": []models.MotionStatus{models.MotionStatusProposed, models.MotionStatusSeconded}},
+		},
+		bson.M{"$set": bson.M{"status": models.MotionStatusWithdrawn, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidTransition
+		}
+		return nil, err
+	}
+
+	auditErr := AuditCommittee(ctx, motion.CommitteeID, models.AuditMotionWithdrawn, &userID, &motion.ID, map[string]string{
+		"title":  motion.Title,
+		"status": string(motion.Status),
+	})
+	deadlinesChanged(motion.ID)
+	unlockMinutes(ctx, &updated)
+
+	if err := withdrawSubsidiaries(ctx, motion.ID); err != nil {
+		return nil, err
+	}
+	if err := closeAgendaItems(ctx, motion.ID); err != nil {
+		return nil, err
+	}
+	// The withdrawal and everything it closed stand; the caller still hears
+	// that the log missed it.
+	if auditErr != nil {
+		return nil, auditErr
+	}
+	return &updated, nil
+}
+
+// withdrawSubsidiaries withdraws whatever is still pending against a motion
+// that has gone away, and whatever is pending against those in turn.
+func withdrawSubsidiaries(ctx context.Context, parentID primitive.ObjectID) error {
+	pending, err := PendingSubsidiaries(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	for i := range pending {
+		sub := &pending[i]
+		if _, err := transitionMotion(ctx, sub, models.MotionStatusWithdrawn, nil, nil); err != nil {
+			if errors.Is(err, ErrInvalidTransition) {
+				continue
+			}
+			return err
+		}
+		deadlinesChanged(sub.ID)
+		if err := withdrawSubsidiaries(ctx, sub.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidMotionKind   = errors.New("unknown motion kind")
+	ErrRefMotionRequired   = errors.New("this motion must reference a previous decision")
+	ErrRefMotionNotPassed  = errors.New("only a passed motion can be reconsidered or rescinded")
+	ErrAlreadyOverturned   = errors.New("this decision has already been overturned")
+	ErrOverturnPending     = errors.New("a motion to overturn this decision is already pending")
+	ErrNotPrevailingVoter  = errors.New("only members who voted on the prevailing side can move to overturn this decision")
+	ErrSecretBallotRecords = errors.New("a decision made by secret ballot has no record of who voted in favor")
+)
+
+var pendingStatuses = []models.MotionStatus{
+	models.MotionStatusProposed,
+	models.MotionStatusSeconded,
+	models.MotionStatusOpen,
+}
+
+func IsOverturnKind(kind models.MotionKind) bool {
+	return kind == models.MotionKindReconsider || kind == models.MotionKindRescind
+}
+
+// MemberVote returns how a member voted on a roll-call motion, or nil if they
+// did not vote.
+func MemberVote(ctx context.Context, motionID, userID primitive.ObjectID) (*models.Vote, error) {
+	var vote models.Vote
+	err := config.GetCollection("votes").FindOne(ctx, bson.M{"motion_id": motionID, "user_id": userID}).Decode(&vote)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &vote, nil
+}
+
+// checkOverturn verifies a reconsider or rescind motion against the decision
+// it targets. Only passed motions qualify, so the prevailing side is always
+// the ayes, and it has to be provable from a roll-call record.
+func checkOverturn(ctx context.Context, committee *models.Committee, moverID primitive.ObjectID, refMotionID *primitive.ObjectID) (*models.Motion, error) {
+	if refMotionID == nil {
+		return nil, ErrRefMotionRequired
+	}
+
+	original, err := GetMotion(ctx, *refMotionID)
+	if err != nil {
+		return nil, err
+	}
+	if original.CommitteeID != committee.ID {
+		return nil, ErrMotionNotFound
+	}
+	if original.Status != models.MotionStatusPassed {
+		return nil, ErrRefMotionNotPassed
+	}
+	if original.OverturnedBy != nil {
+		return nil, ErrAlreadyOverturned
+	}
+	if IsSecretBallot(original) {
+		return nil, ErrSecretBallotRecords
+	}
+
+	vote, err := MemberVote(ctx, original.ID, moverID)
+	if err != nil {
+		return nil, err
+	}
+	if vote == nil || vote.Result != models.VoteAye {
+		return nil, ErrNotPrevailingVoter
+	}
+
+	pending, err := config.GetCollection("motions").CountDocuments(ctx, bson.M{
+		"ref_motion_id": original.ID,
+		"kind":          bson.M{"$in": []models.MotionKind{models.MotionKindReconsider, models.MotionKindRescind}},
+		"status":        bson.M{"$in": pendingStatuses},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, ErrOverturnPending
+	}
+
+	return original, nil
+}
+
+// Rescinding needs two-thirds of the votes cast unless a stricter rule was
+// asked for; reconsideration uses the ordinary threshold.
+func overturnThreshold(kind models.MotionKind, requested models.VoteThreshold) (models.VoteThreshold, error) {
+	threshold, err := ResolveThreshold(requested, false)
+	if err != nil {
+		return "", err
+	}
+	if kind == models.MotionKindRescind {
+		switch threshold {
+		case models.ThresholdMajorityEntire, models.ThresholdUnanimousConsent:
+			return threshold, nil
+		}
+		return models.ThresholdTwoThirds, nil
+	}
+	return threshold, nil
+}
+
+func markOverturned(ctx context.Context, motion *models.Motion) error {
+	if motion.RefMotionID == nil {
+		return nil
+	}
+
+	now := time.Now()
+	_, err := config.GetCollection("motions").UpdateOne(ctx,
+		bson.M{"_id": *motion.RefMotionID, "overturned_by": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"overturned_by": motion.ID,
+			"overturned_at": now,
+			"updated_at":    now,
+		}},
+	)
+	return err
+}
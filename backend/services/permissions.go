@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Capability string
+
+const (
+	CapViewCommittee    Capability = "view_committee"
+	CapProposeMotion    Capability = "propose_motion"
+	CapSecondMotion     Capability = "second_motion"
+	CapDebate           Capability = "debate"
+	CapVote             Capability = "vote"
+	CapCallQuestion     Capability = "call_question"
+	CapPreside          Capability = "preside"
+	CapCloseVoting      Capability = "close_voting"
+	CapEditMinutes      Capability = "edit_minutes"
+	CapManageMembers    Capability = "manage_members"
+	CapManageCommittee  Capability = "manage_committee"
+	CapAssignChair      Capability = "assign_chair"
+	CapArchiveCommittee Capability = "archive_committee"
+	CapDeleteCommittee  Capability = "delete_committee"
+)
+
+var (
+	ErrCommitteeNotFound  = errors.New("committee not found")
+	ErrNotCommitteeMember = errors.New("not a member of this committee")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrCommitteeArchived  = errors.New("committee is archived")
+)
+
+var memberCapabilities = []Capability{
+	CapViewCommittee,
+	CapProposeMotion,
+	CapSecondMotion,
+	CapDebate,
+	CapVote,
+	CapCallQuestion,
+}
+
+var chairCapabilities = append([]Capability{
+	CapPreside,
+	CapCloseVoting,
+	CapEditMinutes,
+	CapManageMembers,
+	CapManageCommittee,
+}, memberCapabilities...)
+
+var roleCapabilities = map[models.CommitteeRole]map[Capability]bool{
+	models.CommitteeRoleOwner: capabilitySet(append([]Capability{
+		CapAssignChair,
+		CapArchiveCommittee,
+		CapDeleteCommittee,
+	}, chairCapabilities...)...),
+	models.CommitteeRoleChair:     capabilitySet(chairCapabilities...),
+	models.CommitteeRoleSecretary: capabilitySet(append([]Capability{CapEditMinutes}, memberCapabilities...)...),
+	models.CommitteeRoleMember:    capabilitySet(memberCapabilities...),
+	models.CommitteeRoleObserver:  capabilitySet(CapViewCommittee),
+}
+
+var archivedCapabilities = capabilitySet(CapViewCommittee, CapDeleteCommittee)
+
+func capabilitySet(caps ...Capability) map[Capability]bool {
+	set := make(map[Capability]bool, len(caps))
+	for _, capability := range caps {
+		set[capability] = true
+	}
+	return set
+}
+
+func RoleOf(committee *models.Committee, userID primitive.ObjectID) models.CommitteeRole {
+	if committee.OwnerID == userID {
+		return models.CommitteeRoleOwner
+	}
+	if committee.ChairID == userID {
+		return models.CommitteeRoleChair
+	}
+	for _, memberID := range committee.MemberIDs {
+		if memberID == userID {
+			// The secretary is a voting member who also keeps the minutes.
+			if committee.SecretaryID != nil && *committee.SecretaryID == userID {
+				return models.CommitteeRoleSecretary
+			}
+			return models.CommitteeRoleMember
+		}
+	}
+	for _, observerID := range committee.ObserverIDs {
+		if observerID == userID {
+			return models.CommitteeRoleObserver
+		}
+	}
+	return models.CommitteeRoleNone
+}
+
+func RoleLabel(role models.CommitteeRole) string {
+	switch role {
+	case models.CommitteeRoleOwner:
+		return "Owner"
+	case models.CommitteeRoleChair:
+		return "Chair"
+	case models.CommitteeRoleSecretary:
+		return "Secretary"
+	case models.CommitteeRoleMember:
+		return "Member"
+	case models.CommitteeRoleObserver:
+		return "Observer"
+	}
+	return ""
+}
+
+func Can(role models.CommitteeRole, capability Capability) bool {
+	return roleCapabilities[role][capability]
+}
+
+func Capabilities(role models.CommitteeRole) []Capability {
+	caps := make([]Capability, 0, len(roleCapabilities[role]))
+	for capability := range roleCapabilities[role] {
+		caps = append(caps, capability)
+	}
+	return caps
+}
+
+func VotingMemberIDs(committee *models.Committee) []primitive.ObjectID {
+	seen := make(map[primitive.ObjectID]bool)
+	ids := []primitive.ObjectID{}
+	for _, id := range append([]primitive.ObjectID{committee.OwnerID, committee.ChairID}, committee.MemberIDs...) {
+		if id.IsZero() || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func GetCommittee(ctx context.Context, committeeID primitive.ObjectID) (*models.Committee, error) {
+	var committee models.Committee
+	err := config.GetCollection("committees").FindOne(ctx, bson.M{"_id": committeeID}).Decode(&committee)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrCommitteeNotFound
+		}
+		return nil, err
+	}
+	return &committee, nil
+}
+
+func AuthorizeCommittee(committee *models.Committee, userID primitive.ObjectID, capability Capability) (models.CommitteeRole, error) {
+	role := RoleOf(committee, userID)
+	if role == models.CommitteeRoleNone {
+		return role, ErrNotCommitteeMember
+	}
+	if !Can(role, capability) {
+		return role, ErrPermissionDenied
+	}
+	if committee.Archived && !archivedCapabilities[capability] {
+		return role, ErrCommitteeArchived
+	}
+	return role, nil
+}
+
+func Authorize(ctx context.Context, committeeID, userID primitive.ObjectID, capability Capability) (*models.Committee, models.CommitteeRole, error) {
+	committee, err := GetCommittee(ctx, committeeID)
+	if err != nil {
+		return nil, models.CommitteeRoleNone, err
+	}
+	role, err := AuthorizeCommittee(committee, userID, capability)
+	return committee, role, err
+}
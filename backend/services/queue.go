@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidStance = errors.New("stance must be pro, con or neutral")
+	ErrFloorClosed   = errors.New("the floor is only open while a debatable motion is pending")
+	ErrNotInQueue    = errors.New("member is not in the speaking queue")
+	ErrQueueEmpty    = errors.New("no one is waiting to speak")
+	ErrNoSpeaker     = errors.New("no one has the floor")
+	ErrNotSpeaker    = errors.New("only the speaker or the chair can yield the floor")
+	ErrQueueChanged  = errors.New("the speaking queue changed while this was being applied")
+)
+
+func ValidStance(stance models.Stance) bool {
+	switch stance {
+	case models.StancePro, models.StanceCon, models.StanceNeutral:
+		return true
+	}
+	return false
+}
+
+func FloorOpen(motion *models.Motion) bool {
+	if !isDebatable(motion) || motion.DebateClosed || debateExpired(motion, time.Now()) {
+		return false
+	}
+	return motion.Status == models.MotionStatusSeconded || motion.Status == models.MotionStatusOpen
+}
+
+func NewSpeakingQueue(motion *models.Motion) *models.SpeakingQueue {
+	return &models.SpeakingQueue{
+		MotionID:    motion.ID,
+		CommitteeID: motion.CommitteeID,
+		Entries:     []models.QueueEntry{},
+		Alternate:   true,
+	}
+}
+
+// ApplyQueueSettings brings the queue's rules in line with the committee's
+// current settings.
+func ApplyQueueSettings(queue *models.SpeakingQueue, settings *models.CommitteeSettings) {
+	queue.Alternate = settings.AlternateSpeakers
+	queue.TimeLimit = settings.SpeakingTimeLimit
+}
+
+// RaiseHand adds the member to the back of the queue, or changes their stance
+// in place if their hand is already up.
+func RaiseHand(queue *models.SpeakingQueue, entry models.QueueEntry) error {
+	if entry.Stance == "" {
+		entry.Stance = models.StanceNeutral
+	}
+	if !ValidStance(entry.Stance) {
+		return ErrInvalidStance
+	}
+
+	if i := queueIndex(queue, entry); i >= 0 {
+		queue.Entries[i].Stance = entry.Stance
+		return nil
+	}
+	queue.Entries = append(queue.Entries, entry)
+	return nil
+}
+
+func LowerHand(queue *models.SpeakingQueue, entry models.QueueEntry) error {
+	i := queueIndex(queue, entry)
+	if i < 0 {
+		return ErrNotInQueue
+	}
+	queue.Entries = append(queue.Entries[:i], queue.Entries[i+1:]...)
+	return nil
+}
+
+// RecognizeSpeaker gives the floor to the given member, or to the suggested
+// speaker when entry is nil. Whoever held the floor before loses it. With a
+// time limit the floor is held until FloorEndsAt.
+func RecognizeSpeaker(queue *models.SpeakingQueue, entry *models.QueueEntry) error {
+	if entry == nil {
+		entry = SuggestNextSpeaker(queue)
+		if entry == nil {
+			return ErrQueueEmpty
+		}
+	}
+
+	i := queueIndex(queue, *entry)
+	if i < 0 {
+		return ErrNotInQueue
+	}
+
+	speaker := queue.Entries[i]
+	queue.Entries = append(queue.Entries[:i], queue.Entries[i+1:]...)
+	queue.Speaker = &speaker
+	if speaker.Stance != models.StanceNeutral {
+		queue.LastStance = speaker.Stance
+	}
+
+	queue.FloorEndsAt = nil
+	if queue.TimeLimit > 0 {
+		endsAt := time.Now().Add(time.Duration(queue.TimeLimit) * time.Second).Truncate(time.Millisecond)
+		queue.FloorEndsAt = &endsAt
+	}
+	return nil
+}
+
+func YieldFloor(queue *models.SpeakingQueue) error {
+	if queue.Speaker == nil {
+		return ErrNoSpeaker
+	}
+	queue.Speaker = nil
+	queue.FloorEndsAt = nil
+	return nil
+}
+
+// SuggestNextSpeaker alternates sides: the earliest hand from the opposite
+// side of the last pro or con speaker, falling back to the front of the queue
+// when that side has no one waiting. Without alternation it is first come,
+// first served.
+func SuggestNextSpeaker(queue *models.SpeakingQueue) *models.QueueEntry {
+	if len(queue.Entries) == 0 {
+		return nil
+	}
+	if !queue.Alternate {
+		return &queue.Entries[0]
+	}
+
+	var want models.Stance
+	switch queue.LastStance {
+	case models.StancePro:
+		want = models.StanceCon
+	case models.StanceCon:
+		want = models.StancePro
+	}
+
+	if want != "" {
+		for i := range queue.Entries {
+			if queue.Entries[i].Stance == want {
+				return &queue.Entries[i]
+			}
+		}
+	}
+	return &queue.Entries[0]
+}
+
+func queueIndex(queue *models.SpeakingQueue, entry models.QueueEntry) int {
+	for i, queued := range queue.Entries {
+		if queued.UserID == entry.UserID {
+			return i
+		}
+	}
+	return -1
+}
+
+func LoadSpeakingQueue(ctx context.Context, motion *models.Motion) (*models.SpeakingQueue, error) {
+	var queue models.SpeakingQueue
+	err := config.GetCollection("speaking_queues").FindOne(ctx, bson.M{"_id": motion.ID}).Decode(&queue)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return NewSpeakingQueue(motion), nil
+		}
+		return nil, err
+	}
+	if queue.Entries == nil {
+		queue.Entries = []models.QueueEntry{}
+	}
+	return &queue, nil
+}
+
+// SaveSpeakingQueue stores the queue only if nobody else has saved it since
+// it was loaded, and returns ErrQueueChanged otherwise. Every instance edits
+// the same queue, so the caller reloads and applies its change again.
+func SaveSpeakingQueue(ctx context.Context, queue *models.SpeakingQueue) error {
+	loaded := queue.Version
+	queue.Version++
+	queue.UpdatedAt = time.Now()
+	_, err := config.GetCollection("speaking_queues").ReplaceOne(ctx,
+		bson.M{"_id": queue.MotionID, "version": versionMatch(loaded)},
+		queue,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		queue.Version = loaded
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrQueueChanged
+		}
+		return err
+	}
+	return nil
+}
+
+// versionMatch matches a document still at the given version. Documents
+// saved before they were versioned have no version and count as zero.
+func versionMatch(version int64) any {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNoQuorum         = errors.New("the meeting does not have a quorum")
+	ErrInvalidQuorum    = errors.New("quorum must be a count no larger than the membership or a fraction between 0 and 1")
+	ErrMeetingAdjourned = errors.New("the meeting has been adjourned")
+	ErrNotCheckedIn     = errors.New("member is not checked in to this meeting")
+)
+
+// ConnectedUser is a user with a live connection to a committee's room.
+// Hidden is set when they do not share their online status.
+type ConnectedUser struct {
+	UserID primitive.ObjectID
+	Hidden bool
+}
+
+// PresenceFunc reports which users have a live connection to a committee's
+// room. The WebSocket hub provides it.
+type PresenceFunc func(committeeID primitive.ObjectID) []ConnectedUser
+
+var connectedUsers PresenceFunc = func(primitive.ObjectID) []ConnectedUser { return nil }
+
+func SetPresenceSource(presence PresenceFunc) {
+	connectedUsers = presence
+}
+
+func ValidateQuorumRule(committee *models.Committee, rule models.QuorumRule) error {
+	if rule.Count < 0 || rule.Fraction < 0 || rule.Fraction > 1 {
+		return ErrInvalidQuorum
+	}
+	if rule.Count > 0 && rule.Fraction > 0 {
+		return ErrInvalidQuorum
+	}
+	if rule.Count > len(VotingMemberIDs(committee)) {
+		return ErrInvalidQuorum
+	}
+	return nil
+}
+
+// RequiredQuorum is the number of voting members needed to do business.
+func RequiredQuorum(committee *models.Committee, rule models.QuorumRule) int {
+	membership := len(VotingMemberIDs(committee))
+
+	switch {
+	case rule.Count > 0:
+		return rule.Count
+	case rule.Fraction > 0:
+		return int(math.Ceil(rule.Fraction * float64(membership)))
+	default:
+		return membership/2 + 1
+	}
+}
+
+// MeetingQuorum counts the voting members who are both checked in to the
+// meeting and connected to the committee room right now. Members who hide
+// their online status count toward quorum without being named.
+func MeetingQuorum(committee *models.Committee, meeting *models.Meeting, rule models.QuorumRule) models.QuorumStatus {
+	connected := make(map[primitive.ObjectID]ConnectedUser)
+	for _, user := range connectedUsers(committee.ID) {
+		connected[user.UserID] = user
+	}
+	checkedIn := make(map[primitive.ObjectID]bool)
+	for _, record := range meeting.Attendance {
+		if record.CheckedOutAt == nil {
+			checkedIn[record.UserID] = true
+		}
+	}
+
+	voters := VotingMemberIDs(committee)
+	present := []primitive.ObjectID{}
+	count := 0
+	for _, userID := range voters {
+		user, ok := connected[userID]
+		if !ok || !checkedIn[userID] {
+			continue
+		}
+		count++
+		if !user.Hidden {
+			present = append(present, userID)
+		}
+	}
+
+	required := RequiredQuorum(committee, rule)
+	return models.QuorumStatus{
+		CommitteeID:  committee.ID,
+		MeetingID:    meeting.ID,
+		Present:      present,
+		PresentCount: count,
+		Required:     required,
+		Membership:   len(voters),
+		HasQuorum:    count >= required,
+	}
+}
+
+// CommitteeQuorum returns the quorum of the committee's meeting in progress,
+// or nil when the committee is not meeting.
+func CommitteeQuorum(ctx context.Context, committeeID primitive.ObjectID) (*models.QuorumStatus, error) {
+	committee, err := GetCommittee(ctx, committeeID)
+	if err != nil {
+		return nil, err
+	}
+	return activeQuorum(ctx, committee)
+}
+
+// RequireQuorum blocks business while the committee is meeting without a
+// quorum. Outside of a meeting the committee works asynchronously and no
+// quorum applies.
+func RequireQuorum(ctx context.Context, committeeID primitive.ObjectID) error {
+	committee, err := GetCommittee(ctx, committeeID)
+	if err != nil {
+		return err
+	}
+	return requireQuorum(ctx, committee)
+}
+
+func requireQuorum(ctx context.Context, committee *models.Committee) error {
+	status, err := activeQuorum(ctx, committee)
+	if err != nil {
+		return err
+	}
+	if status != nil && !status.HasQuorum {
+		return ErrNoQuorum
+	}
+	return nil
+}
+
+func activeQuorum(ctx context.Context, committee *models.Committee) (*models.QuorumStatus, error) {
+	meeting, err := ActiveMeeting(ctx, committee.ID)
+	if err != nil || meeting == nil {
+		return nil, err
+	}
+	settings, err := GetSettings(ctx, committee.ID)
+	if err != nil {
+		return nil, err
+	}
+	status := MeetingQuorum(committee, meeting, settings.Quorum)
+	return &status, nil
+}
+
+// AnnounceQuorum records status as the meeting's announced quorum and
+// returns the one announced before it. It reports false when the count is
+// unchanged since the last announcement, so each change is announced once
+// however many instances notice it.
+func AnnounceQuorum(ctx context.Context, status *models.QuorumStatus) (*models.QuorumAnnouncement, bool, error) {
+	next := models.QuorumAnnouncement{
+		PresentCount: status.PresentCount,
+		Required:     status.Required,
+		HasQuorum:    status.HasQuorum,
+	}
+
+	for {
+		meeting, err := GetMeeting(ctx, status.MeetingID)
+		if err != nil {
+			return nil, false, err
+		}
+		if meeting.Quorum != nil && *meeting.Quorum == next {
+			return meeting.Quorum, false, nil
+		}
+
+		result, err := config.GetCollection("meetings").UpdateOne(ctx,
+			bson.M{"_id": meeting.ID, "quorum_seq": versionMatch(meeting.QuorumSeq)},
+			bson.M{
+				"$set": bson.M{"quorum": next},
+				"$inc": bson.M{"quorum_seq": 1},
+			},
+		)
+		if err != nil {
+			return nil, false, err
+		}
+		// Losing the race means another announcement was recorded; compare
+		// against that one instead.
+		if result.MatchedCount == 1 {
+			return meeting.Quorum, true, nil
+		}
+	}
+}
+
+// CheckIn adds the member to the meeting's attendance roll. Checking in again
+// after checking out keeps the original arrival time.
+func CheckIn(ctx context.Context, meetingID, userID primitive.ObjectID) (*models.Meeting, error) {
+	meeting, err := GetMeeting(ctx, meetingID)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := Authorize(ctx, meeting.CommitteeID, userID, CapViewCommittee); err != nil {
+		return nil, err
+	}
+	if meeting.Status == models.MeetingStatusAdjourned {
+		return nil, ErrMeetingAdjourned
+	}
+
+	collection := config.GetCollection("meetings")
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": meeting.ID, "attendance.user_id": userID},
+		bson.M{
+			"$unset": bson.M{"attendance.$.checked_out_at": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.MatchedCount == 0 {
+		now := time.Now()
+		record := models.AttendanceRecord{UserID: userID, CheckedInAt: now}
+		_, err = collection.UpdateOne(ctx,
+			bson.M{"_id": meeting.ID, "attendance.user_id": bson.M{"$ne": userID}},
+			bson.M{
+				"$push": bson.M{"attendance": record},
+				"$set":  bson.M{"updated_at": now},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return GetMeeting(ctx, meeting.ID)
+}
+
+func CheckOut(ctx context.Context, meetingID, userID primitive.ObjectID) (*models.Meeting, error) {
+	meeting, err := GetMeeting(ctx, meetingID)
+	if err != nil {
+		return nil, err
+	}
+	if meeting.Status == models.MeetingStatusAdjourned {
+		return nil, ErrMeetingAdjourned
+	}
+
+	now := time.Now()
+	var updated models.Meeting
+	err = config.GetCollection("meetings").FindOneAndUpdate(ctx,
+		bson.M{
+			"_id": meeting.ID,
+			"attendance": bson.M{"$elemMatch": bson.M{
+				"user_id":        userID,
+				"checked_out_at": bson.M{"$exists": false},
+			}},
+		},
+		bson.M{"$set": bson.M{
+			"attendance.$.checked_out_at": now,
+			"updated_at":                  now,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotCheckedIn
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMeetingQuorumCountsHiddenMembersWithoutNamingThem(t *testing.T) {
+	shown, hidden, away := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	committee := &models.Committee{
+		ID:        primitive.NewObjectID(),
+		OwnerID:   shown,
+		ChairID:   shown,
+		MemberIDs: []primitive.ObjectID{shown, hidden, away},
+	}
+	meeting := &models.Meeting{
+		ID: primitive.NewObjectID(),
+		Attendance: []models.AttendanceRecord{
+			{UserID: shown, CheckedInAt: time.Now()},
+			{UserID: hidden, CheckedInAt: time.Now()},
+			{UserID: away, CheckedInAt: time.Now()},
+		},
+	}
+
+	previous := connectedUsers
+	t.Cleanup(func() { connectedUsers = previous })
+	SetPresenceSource(func(primitive.ObjectID) []ConnectedUser {
+		return []ConnectedUser{{UserID: shown}, {UserID: hidden, Hidden: true}}
+	})
+
+	status := MeetingQuorum(committee, meeting, models.QuorumRule{})
+	if status.PresentCount != 2 || status.Required != 2 || !status.HasQuorum {
+		t.Errorf("got %d present of %d required (quorum %v), want 2 of 2 with quorum",
+			status.PresentCount, status.Required, status.HasQuorum)
+	}
+	if len(status.Present) != 1 || status.Present[0] != shown {
+		t.Errorf("present lists %v, want only %s", status.Present, shown.Hex())
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNotChairRuled     = errors.New("the chair does not rule on this kind of motion")
+	ErrInvalidRuling     = errors.New("ruling must be sustained or not_sustained")
+	ErrNoRulingToAppeal  = errors.New("an appeal must reference a ruling of the chair")
+	ErrAppealPending     = errors.New("this ruling has already been appealed")
+	ErrChairCannotAppeal = errors.New("the chair cannot appeal their own ruling")
+)
+
+// RuleOnMotion records the chair's decision on a point of order or question
+// of privilege. These never go to a vote; the committee's recourse is an
+// appeal.
+func RuleOnMotion(ctx context.Context, motionID, chairID primitive.ObjectID, decision models.RulingDecision, explanation string) (*models.Motion, error) {
+	motion, err := GetMotion(ctx, motionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := Authorize(ctx, motion.CommitteeID, chairID, CapPreside); err != nil {
+		return nil, err
+	}
+
+	if spec, _ := SpecFor(motion.Kind); !spec.ChairRules {
+		return nil, ErrNotChairRuled
+	}
+	if decision != models.RulingSustained && decision != models.RulingNotSustained {
+		return nil, ErrInvalidRuling
+	}
+
+	ruling := models.ChairRuling{
+		ChairID:     chairID,
+		Decision:    decision,
+		Explanation: strings.TrimSpace(explanation),
+		RuledAt:     time.Now(),
+	}
+	return transitionMotion(ctx, motion, models.MotionStatusRuled, bson.M{"ruling": ruling}, &chairID)
+}
+
+func checkAppeal(ctx context.Context, committee *models.Committee, moverID primitive.ObjectID, refMotionID *primitive.ObjectID) (*models.Motion, error) {
+	if refMotionID == nil {
+		return nil, ErrNoRulingToAppeal
+	}
+
+	ruled, err := GetMotion(ctx, *refMotionID)
+	if err != nil {
+		return nil, err
+	}
+	if ruled.CommitteeID != committee.ID {
+		return nil, ErrMotionNotFound
+	}
+	if ruled.Status != models.MotionStatusRuled || ruled.Ruling == nil {
+		return nil, ErrNoRulingToAppeal
+	}
+	if ruled.Ruling.ChairID == moverID {
+		return nil, ErrChairCannotAppeal
+	}
+
+	// An appeal that expired unseconded or was withdrawn was never taken up,
+	// so the ruling can still be appealed.
+	live := append(append([]models.MotionStatus{}, pendingStatuses...), decidedStatuses...)
+	appeals, err := config.GetCollection("motions").CountDocuments(ctx, bson.M{
+		"ref_motion_id": ruled.ID,
+		"kind":          models.MotionKindAppeal,
+		"status":        bson.M{"$in": live},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if appeals > 0 {
+		return nil, ErrAppealPending
+	}
+
+	return ruled, nil
+}
+
+// The question on an appeal is whether the ruling should be overturned, so a
+// tie leaves the chair's ruling standing.
+func overturnRuling(ctx context.Context, appeal *models.Motion) error {
+	if appeal.RefMotionID == nil {
+		return nil
+	}
+
+	_, err := config.GetCollection("motions").UpdateOne(ctx,
+		bson.M{"_id": *appeal.RefMotionID, "ruling.overturned_by": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"ruling.overturned_by": appeal.ID,
+			"updated_at":           time.Now(),
+		}},
+	)
+	return err
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxSpeakingTimeLimit = 60 * 60
+	maxDeadlineWindow    = 30 * 24 * 60 * 60
+)
+
+var (
+	ErrNoSettingsChange       = errors.New("no settings to change")
+	ErrInvalidMode            = errors.New("mode must be async or in_person")
+	ErrInvalidTimeLimit       = errors.New("speaking time limit must be between 0 and 3600 seconds")
+	ErrInvalidWindow          = errors.New("deadline windows must be between 0 and 30 days")
+	ErrSettingsChangeRequired = errors.New("a change_procedure motion must carry a settings change")
+	ErrNotInSession           = errors.New("this committee only does business while a meeting is in progress")
+)
+
+func DefaultSettings(committeeID primitive.ObjectID) *models.CommitteeSettings {
+	return &models.CommitteeSettings{
+		CommitteeID:       committeeID,
+		DefaultThreshold:  models.ThresholdMajority,
+		RequireSeconds:    true,
+		AlternateSpeakers: true,
+		Mode:              models.CommitteeModeAsync,
+	}
+}
+
+// GetSettings returns the committee's settings, or the defaults for a
+// committee that has never changed them.
+func GetSettings(ctx context.Context, committeeID primitive.ObjectID) (*models.CommitteeSettings, error) {
+	settings := DefaultSettings(committeeID)
+	err := config.GetCollection("committee_settings").FindOne(ctx, bson.M{"_id": committeeID}).Decode(settings)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return settings, nil
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+// UpdateSettings applies a change right away, unless the committee protects
+// its settings; then the change is moved as a change_procedure motion and
+// only takes effect if two-thirds adopt it.
+func UpdateSettings(ctx context.Context, committeeID, userID primitive.ObjectID, change models.SettingsChange) (*models.CommitteeSettings, *models.Motion, error) {
+	committee, _, err := Authorize(ctx, committeeID, userID, CapPreside)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateSettingsChange(committee, change); err != nil {
+		return nil, nil, err
+	}
+
+	settings, err := GetSettings(ctx, committeeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if settings.ProtectSettings {
+		motion, err := ProposeMotion(ctx, committee, userID, ProposeMotionInput{
+			Title:          "Change procedural settings",
+			Description:    DescribeSettingsChange(change),
+			Kind:           models.MotionKindProcedure,
+			SettingsChange: &change,
+		})
+		return nil, motion, err
+	}
+
+	updated, err := saveSettings(ctx, committeeID, userID, change)
+	if err != nil {
+		return nil, nil, err
+	}
+	AuditCommittee(ctx, committeeID, models.AuditSettingsChanged, &userID, nil, map[string]string{
+		"change": DescribeSettingsChange(change),
+	})
+	return updated, nil, nil
+}
+
+func validateSettingsChange(committee *models.Committee, change models.SettingsChange) error {
+	if change == (models.SettingsChange{}) {
+		return ErrNoSettingsChange
+	}
+	if change.DefaultThreshold != nil && !ValidThreshold(*change.DefaultThreshold) {
+		return ErrInvalidThreshold
+	}
+	if change.SpeakingTimeLimit != nil && (*change.SpeakingTimeLimit < 0 || *change.SpeakingTimeLimit > maxSpeakingTimeLimit) {
+		return ErrInvalidTimeLimit
+	}
+	if change.Mode != nil && *change.Mode != models.CommitteeModeAsync && *change.Mode != models.CommitteeModeInPerson {
+		return ErrInvalidMode
+	}
+	for _, window := range []*int{change.SecondingWindow, change.DebateWindow, change.VotingWindow, change.ReminderLead} {
+		if window != nil && (*window < 0 || *window > maxDeadlineWindow) {
+			return ErrInvalidWindow
+		}
+	}
+	if change.Quorum != nil {
+		return ValidateQuorumRule(committee, *change.Quorum)
+	}
+	return nil
+}
+
+func saveSettings(ctx context.Context, committeeID, userID primitive.ObjectID, change models.SettingsChange) (*models.CommitteeSettings, error) {
+	defaults := DefaultSettings(committeeID)
+	now := time.Now()
+
+	set := bson.M{"updated_by": userID, "updated_at": now}
+	setOnInsert := bson.M{
+		"default_threshold":           defaults.DefaultThreshold,
+		"require_seconds":             defaults.RequireSeconds,
+		"secret_by_default":           defaults.SecretByDefault,
+		"speaking_time_limit_seconds": defaults.SpeakingTimeLimit,
+		"alternate_speakers":          defaults.AlternateSpeakers,
+		"mode":                        defaults.Mode,
+		"quorum":                      defaults.Quorum,
+		"seconding_window_seconds":    defaults.SecondingWindow,
+		"debate_window_seconds":       defaults.DebateWindow,
+		"voting_window_seconds":       defaults.VotingWindow,
+		"reminder_lead_seconds":       defaults.ReminderLead,
+		"protect_settings":            defaults.ProtectSettings,
+	}
+	apply := func(key string, value any) {
+		set[key] = value
+		delete(setOnInsert, key)
+	}
+
+	if change.DefaultThreshold != nil {
+		apply("default_threshold", *change.DefaultThreshold)
+	}
+	if change.RequireSeconds != nil {
+		apply("require_seconds", *change.RequireSeconds)
+	}
+	if change.SecretByDefault != nil {
+		apply("secret_by_default", *change.SecretByDefault)
+	}
+	if change.SpeakingTimeLimit != nil {
+		apply("speaking_time_limit_seconds", *change.SpeakingTimeLimit)
+	}
+	if change.AlternateSpeakers != nil {
+		apply("alternate_speakers", *change.AlternateSpeakers)
+	}
+	if change.Mode != nil {
+		apply("mode", *change.Mode)
+	}
+	if change.Quorum != nil {
+		apply("quorum", *change.Quorum)
+	}
+	if change.SecondingWindow != nil {
+		apply("seconding_window_seconds", *change.SecondingWindow)
+	}
+	if change.DebateWindow != nil {
+		apply("debate_window_seconds", *change.DebateWindow)
+	}
+	if change.VotingWindow != nil {
+		apply("voting_window_seconds", *change.VotingWindow)
+	}
+	if change.ReminderLead != nil {
+		apply("reminder_lead_seconds", *change.ReminderLead)
+	}
+	if change.ProtectSettings != nil {
+		apply("protect_settings", *change.ProtectSettings)
+	}
+
+	var settings models.CommitteeSettings
+	err := config.GetCollection("committee_settings").FindOneAndUpdate(ctx,
+		bson.M{"_id": committeeID},
+		bson.M{"$set": set, "$setOnInsert": setOnInsert},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&settings)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// applySettingsChange carries out an adopted change_procedure motion on
+// behalf of its mover.
+func applySettingsChange(ctx context.Context, motion *models.Motion) error {
+	if motion.SettingsChange == nil {
+		return nil
+	}
+	if _, err := saveSettings(ctx, motion.CommitteeID, motion.MoverID, *motion.SettingsChange); err != nil {
+		return err
+	}
+	AuditCommittee(ctx, motion.CommitteeID, models.AuditSettingsChanged, nil, &motion.ID, map[string]string{
+		"change": DescribeSettingsChange(*motion.SettingsChange),
+	})
+	return nil
+}
+
+// DescribeSettingsChange spells out a change for the motion that proposes it.
+func DescribeSettingsChange(change models.SettingsChange) string {
+	var parts []string
+	if change.DefaultThreshold != nil {
+		parts = append(parts, fmt.Sprintf("default threshold to %s", thresholdLabels[*change.DefaultThreshold]))
+	}
+	if change.RequireSeconds != nil {
+		parts = append(parts, fmt.Sprintf("require seconds: %s", onOff(*change.RequireSeconds)))
+	}
+	if change.SecretByDefault != nil {
+		parts = append(parts, fmt.Sprintf("secret ballot by default: %s", onOff(*change.SecretByDefault)))
+	}
+	if change.SpeakingTimeLimit != nil {
+		parts = append(parts, describeSeconds("speaking time limit", *change.SpeakingTimeLimit))
+	}
+	if change.AlternateSpeakers != nil {
+		parts = append(parts, fmt.Sprintf("alternate pro and con speakers: %s", onOff(*change.AlternateSpeakers)))
+	}
+	if change.Mode != nil {
+		parts = append(parts, fmt.Sprintf("mode to %s", *change.Mode))
+	}
+	if change.Quorum != nil {
+		switch {
+		case change.Quorum.Count > 0:
+			parts = append(parts, fmt.Sprintf("quorum to %d members", change.Quorum.Count))
+		case change.Quorum.Fraction > 0:
+			parts = append(parts, fmt.Sprintf("quorum to %.0f%% of the members", change.Quorum.Fraction*100))
+		default:
+			parts = append(parts, "quorum to a majority of the members")
+		}
+	}
+	if change.SecondingWindow != nil {
+		parts = append(parts, describeSeconds("seconding window", *change.SecondingWindow))
+	}
+	if change.DebateWindow != nil {
+		parts = append(parts, describeSeconds("debate window", *change.DebateWindow))
+	}
+	if change.VotingWindow != nil {
+		parts = append(parts, describeSeconds("voting window", *change.VotingWindow))
+	}
+	if change.ReminderLead != nil {
+		parts = append(parts, describeSeconds("reminder lead time", *change.ReminderLead))
+	}
+	if change.ProtectSettings != nil {
+		parts = append(parts, fmt.Sprintf("require a two-thirds vote to change settings: %s", onOff(*change.ProtectSettings)))
+	}
+	return "Change " + strings.Join(parts, "; ") + "."
+}
+
+func describeSeconds(setting string, seconds int) string {
+	if seconds == 0 {
+		return "no " + setting
+	}
+	return fmt.Sprintf("%s to %s", setting, time.Duration(seconds)*time.Second)
+}
+
+func onOff(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
+
+// requireSession keeps committees that meet in person from doing business
+// between meetings.
+func requireSession(ctx context.Context, settings *models.CommitteeSettings) error {
+	if settings.Mode != models.CommitteeModeInPerson {
+		return nil
+	}
+	meeting, err := ActiveMeeting(ctx, settings.CommitteeID)
+	if err != nil {
+		return err
+	}
+	if meeting == nil {
+		return ErrNotInSession
+	}
+	return nil
+}
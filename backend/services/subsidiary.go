@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrParentMotionRequired  = errors.New("subsidiary motions must name the main motion they apply to")
+	ErrInvalidParentMotion   = errors.New("this motion can only apply to a main motion")
+	ErrNotAmendable          = errors.New("the pending motion cannot be amended")
+	ErrParentNotPending      = errors.New("the main motion is not under consideration")
+	ErrOutOfOrder            = errors.New("a motion of equal or higher rank is already pending")
+	ErrHigherMotionPending   = errors.New("a higher-ranking pending question must be resolved first")
+	ErrAmendmentTextRequired = errors.New("an amendment must give the new wording")
+	ErrInvalidPostponement   = errors.New("a motion can only be postponed to a future time")
+)
+
+type SubsidiaryInput struct {
+	ParentMotionID     *primitive.ObjectID
+	Amendment          *models.AmendmentText
+	PostponeUntil      *time.Time
+	ReferToCommitteeID *primitive.ObjectID
+}
+
+// PendingSubsidiaries returns the unresolved motions attached to a main
+// motion, highest rank first: the order in which they must be decided.
+func PendingSubsidiaries(ctx context.Context, parentID primitive.ObjectID) ([]models.Motion, error) {
+	cursor, err := config.GetCollection("motions").Find(ctx, bson.M{
+		"parent_motion_id": parentID,
+		"status":           bson.M{"$in": pendingStatuses},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	motions := []models.Motion{}
+	if err := cursor.All(ctx, &motions); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(motions, func(i, j int) bool {
+		return MotionRank(motions[i].Kind) > MotionRank(motions[j].Kind)
+	})
+	return motions, nil
+}
+
+// PendingQuestions is the full stack for a main motion: pending subsidiaries
+// from the top down, with the main motion at the bottom.
+func PendingQuestions(ctx context.Context, main *models.Motion) ([]models.Motion, error) {
+	stack, err := PendingSubsidiaries(ctx, main.ID)
+	if err != nil {
+		return nil, err
+	}
+	return append(stack, *main), nil
+}
+
+// checkAttachment validates the motion a subsidiary, privileged or incidental
+// motion is made against. Subsidiaries must name one; the others may.
+func checkAttachment(ctx context.Context, committee *models.Committee, spec MotionSpec, input SubsidiaryInput) (*models.Motion, error) {
+	if input.ParentMotionID == nil {
+		if spec.Class == models.MotionClassSubsidiary {
+			return nil, ErrParentMotionRequired
+		}
+		return nil, nil
+	}
+
+	parent, err := GetMotion(ctx, *input.ParentMotionID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.CommitteeID != committee.ID {
+		return nil, ErrMotionNotFound
+	}
+	if !isMainClass(parent.Kind) {
+		return nil, ErrInvalidParentMotion
+	}
+	if parent.Status != models.MotionStatusOpen {
+		return nil, ErrParentNotPending
+	}
+
+	if spec.Class != models.MotionClassIncidental {
+		pending, err := PendingSubsidiaries(ctx, parent.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) > 0 && MotionRank(pending[0].Kind) >= spec.Rank {
+			return nil, ErrOutOfOrder
+		}
+	}
+
+	switch spec.Kind {
+	case models.MotionKindAmend:
+		if parentSpec, _ := SpecFor(parent.Kind); !parentSpec.Amendable {
+			return nil, ErrNotAmendable
+		}
+		if input.Amendment == nil || (strings.TrimSpace(input.Amendment.Title) == "" && strings.TrimSpace(input.Amendment.Description) == "") {
+			return nil, ErrAmendmentTextRequired
+		}
+	case models.MotionKindPostpone:
+		if input.PostponeUntil == nil || !input.PostponeUntil.After(time.Now()) {
+			return nil, ErrInvalidPostponement
+		}
+	case models.MotionKindRefer:
+		if input.ReferToCommitteeID != nil {
+			if _, err := GetCommittee(ctx, *input.ReferToCommitteeID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return parent, nil
+}
+
+// checkImmediatelyPending enforces top-down resolution: a question can only
+// be voted on when nothing of higher rank is pending above it.
+func checkImmediatelyPending(ctx context.Context, motion *models.Motion) error {
+	parentID := motion.ID
+	if motion.ParentMotionID != nil {
+		parent, err := GetMotion(ctx, *motion.ParentMotionID)
+		if err != nil {
+			return err
+		}
+		if parent.Status != models.MotionStatusOpen {
+			return ErrParentNotPending
+		}
+		parentID = parent.ID
+	}
+
+	pending, err := PendingSubsidiaries(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	for _, other := range pending {
+		if other.ID == motion.ID {
+			continue
+		}
+		if motion.ParentMotionID == nil || MotionRank(other.Kind) > MotionRank(motion.Kind) {
+			return ErrHigherMotionPending
+		}
+	}
+	return nil
+}
+
+// applySubsidiary carries out an adopted subsidiary motion on its parent.
+// Privileged motions attached to a parent leave it untouched.
+func applySubsidiary(ctx context.Context, motion *models.Motion) error {
+	if motion.ParentMotionID == nil {
+		return nil
+	}
+
+	parent, err := GetMotion(ctx, *motion.ParentMotionID)
+	if err != nil {
+		return err
+	}
+
+	switch motion.Kind {
+	case models.MotionKindAmend:
+		return amendMotion(ctx, parent, motion)
+	case models.MotionKindPostpone:
+		_, err = transitionMotion(ctx, parent, models.MotionStatusPostponed, bson.M{"postpone_until": motion.PostponeUntil}, nil)
+	case models.MotionKindRefer:
+		extra := bson.M{}
+		if motion.ReferToCommitteeID != nil {
+			extra["refer_to_committee_id"] = motion.ReferToCommitteeID
+		}
+		_, err = transitionMotion(ctx, parent, models.MotionStatusReferred, extra, nil)
+	case models.MotionKindTable:
+		_, err = transitionMotion(ctx, parent, models.MotionStatusTabled, nil, nil)
+	case models.MotionKindCallQuestion:
+		_, err = config.GetCollection("motions").UpdateOne(ctx,
+			bson.M{"_id": parent.ID},
+			bson.M{"$set": bson.M{"debate_closed": true, "updated_at": time.Now()}},
+		)
+	}
+	return err
+}
+
+func amendMotion(ctx context.Context, parent, amendment *models.Motion) error {
+	title := parent.Title
+	if newTitle := strings.TrimSpace(amendment.Amendment.Title); newTitle != "" {
+		title = newTitle
+	}
+	// An amendment that only retitles the motion leaves its text alone.
+	description := parent.Description
+	if strings.TrimSpace(amendment.Amendment.Description) != "" {
+		description = amendment.Amendment.Description
+	}
+
+	revision := models.TextRevision{
+		AmendmentID:         amendment.ID,
+		PreviousTitle:       parent.Title,
+		PreviousDescription: parent.Description,
+		DescriptionDiff:     utils.DiffWords(parent.Description, description),
+		AppliedAt:           time.Now(),
+	}
+	if title != parent.Title {
+		revision.TitleDiff = utils.DiffWords(parent.Title, title)
+	}
+
+	err := config.GetCollection("motions").FindOneAndUpdate(ctx,
+		bson.M{"_id": parent.ID, "status": models.MotionStatusOpen},
+		bson.M{
+			"$set": bson.M{
+				"title":       title,
+				"description": description,
+				"updated_at":  revision.AppliedAt,
+			},
+			"$push": bson.M{"text_history": revision},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Err()
+	if err == mongo.ErrNoDocuments {
+		return ErrParentNotPending
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNoMotionToSuspend  = errors.New("suspending the rules must reference a pending motion")
+	ErrInvalidSuspendRule = errors.New("suspended rule must be second or debate")
+	ErrRuleDoesNotApply   = errors.New("that rule does not apply to the motion in its current state")
+)
+
+func checkSuspension(ctx context.Context, committee *models.Committee, refMotionID *primitive.ObjectID, rule models.SuspendedRule) (*models.Motion, error) {
+	if refMotionID == nil {
+		return nil, ErrNoMotionToSuspend
+	}
+	if rule != models.SuspendSecond && rule != models.SuspendDebate {
+		return nil, ErrInvalidSuspendRule
+	}
+
+	target, err := GetMotion(ctx, *refMotionID)
+	if err != nil {
+		return nil, err
+	}
+	if target.CommitteeID != committee.ID {
+		return nil, ErrMotionNotFound
+	}
+	if !suspensionApplies(target, rule) {
+		return nil, ErrRuleDoesNotApply
+	}
+	return target, nil
+}
+
+// suspensionApplies reports whether setting the rule aside would change
+// anything for the motion: a second is only owed while it is proposed, and
+// debate only runs on a debatable motion that has not closed it.
+func suspensionApplies(target *models.Motion, rule models.SuspendedRule) bool {
+	switch rule {
+	case models.SuspendSecond:
+		spec, _ := SpecFor(target.Kind)
+		return target.Status == models.MotionStatusProposed && spec.RequiresSecond
+	case models.SuspendDebate:
+		return (target.Status == models.MotionStatusSeconded || target.Status == models.MotionStatusOpen) &&
+			isDebatable(target) && !target.DebateClosed
+	}
+	return false
+}
+
+// applySuspension sets the rule aside for the referenced motion: it is
+// treated as seconded, or its debate is closed.
+func applySuspension(ctx context.Context, motion *models.Motion) error {
+	if motion.RefMotionID == nil {
+		return nil
+	}
+
+	target, err := GetMotion(ctx, *motion.RefMotionID)
+	if err != nil {
+		return err
+	}
+	if !suspensionApplies(target, motion.SuspendedRule) {
+		return ErrRuleDoesNotApply
+	}
+
+	switch motion.SuspendedRule {
+	case models.SuspendSecond:
+		settings, err := GetSettings(ctx, target.CommitteeID)
+		if err != nil {
+			return err
+		}
+		seconded, err := transitionMotion(ctx, target, models.MotionStatusSeconded, nil, nil)
+		if err != nil {
+			return err
+		}
+		_, err = openAsync(ctx, seconded, settings)
+		return err
+	case models.SuspendDebate:
+		if _, err := config.GetCollection("motions").UpdateOne(ctx,
+			bson.M{"_id": target.ID, "status": bson.M{"$in": []models.MotionStatus{models.MotionStatusSeconded, models.MotionStatusOpen}}},
+			bson.M{"$set": bson.M{"debate_closed": true, "updated_at": time.Now()}},
+		); err != nil {
+			return err
+		}
+		deadlinesChanged(target.ID)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zach-short/final-web-programming/models"
+)
+
+var ErrInvalidThreshold = errors.New("unknown vote threshold")
+
+var thresholdLabels = map[models.VoteThreshold]string{
+	models.ThresholdMajority:         "Majority of votes cast",
+	models.ThresholdTwoThirds:        "Two-thirds of votes cast",
+	models.ThresholdMajorityPresent:  "Majority of members present",
+	models.ThresholdMajorityEntire:   "Majority of the entire membership",
+	models.ThresholdUnanimousConsent: "Unanimous consent",
+}
+
+func ValidThreshold(threshold models.VoteThreshold) bool {
+	_, ok := thresholdLabels[threshold]
+	return ok
+}
+
+func ResolveThreshold(requested models.VoteThreshold, isSpecial bool) (models.VoteThreshold, error) {
+	if requested == "" {
+		if isSpecial {
+			return models.ThresholdTwoThirds, nil
+		}
+		return models.ThresholdMajority, nil
+	}
+	if !ValidThreshold(requested) {
+		return "", ErrInvalidThreshold
+	}
+	return requested, nil
+}
+
+// EvaluateTally applies the threshold to a raw count. Abstentions never count
+// as votes cast; they only matter for the present and entire-membership rules
+// by failing to add to the ayes.
+func EvaluateTally(tally models.VoteTally, threshold models.VoteThreshold, membership, present int) models.VoteTally {
+	if threshold == "" {
+		threshold = models.ThresholdMajority
+	}
+
+	cast := tally.Ayes + tally.Nays
+	tally.Threshold = threshold
+	tally.Membership = membership
+	tally.Present = present
+
+	var basis int
+	var basisLabel string
+	switch threshold {
+	case models.ThresholdTwoThirds:
+		basis, basisLabel = cast, "votes cast"
+		tally.Required = (2*basis + 2) / 3
+	case models.ThresholdMajorityPresent:
+		basis, basisLabel = present, "members present"
+		tally.Required = basis/2 + 1
+	case models.ThresholdMajorityEntire:
+		basis, basisLabel = membership, "members"
+		tally.Required = basis/2 + 1
+	case models.ThresholdUnanimousConsent:
+		basis, basisLabel = cast, "votes cast"
+		tally.Required = basis
+	default:
+		basis, basisLabel = cast, "votes cast"
+		tally.Required = basis/2 + 1
+	}
+
+	passed := basis > 0 && tally.Ayes >= tally.Required
+	if threshold == models.ThresholdUnanimousConsent {
+		passed = tally.Ayes > 0 && tally.Nays == 0
+	}
+
+	tally.Result = models.MotionStatusFailed
+	if passed {
+		tally.Result = models.MotionStatusPassed
+	}
+
+	tally.Rule = fmt.Sprintf("%s: %d aye of %d %s (%d required, %d nay, %d abstaining not counted)",
+		thresholdLabels[threshold], tally.Ayes, basis, basisLabel, tally.Required, tally.Nays, tally.Abstentions)
+
+	return tally
+}
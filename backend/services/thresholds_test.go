@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/zach-short/final-web-programming/models"
+)
+
+func TestEvaluateTally(t *testing.T) {
+	tests := []struct {
+		name                    string
+		threshold               models.VoteThreshold
+		ayes, nays, abstentions int
+		membership, present     int
+		required                int
+		passed                  bool
+	}{
+		{"majority by one", models.ThresholdMajority, 4, 3, 0, 9, 9, 4, true},
+		{"majority tie", models.ThresholdMajority, 3, 3, 1, 9, 9, 4, false},
+		{"majority ignores abstentions", models.ThresholdMajority, 2, 1, 5, 9, 9, 2, true},
+		{"majority all abstain", models.ThresholdMajority, 0, 0, 5, 9, 9, 1, false},
+		{"majority zero ayes", models.ThresholdMajority, 0, 2, 0, 9, 9, 2, false},
+		{"default is majority", "", 1, 0, 0, 9, 9, 1, true},
+
+		{"two-thirds exactly", models.ThresholdTwoThirds, 6, 3, 0, 9, 9, 6, true},
+		{"two-thirds one short", models.ThresholdTwoThirds, 5, 3, 0, 9, 9, 6, false},
+		{"two-thirds rounds up", models.ThresholdTwoThirds, 2, 1, 0, 9, 9, 2, true},
+		{"two-thirds tie", models.ThresholdTwoThirds, 1, 1, 0, 9, 9, 2, false},
+		{"two-thirds all abstain", models.ThresholdTwoThirds, 0, 0, 4, 9, 9, 0, false},
+		{"two-thirds ignores abstentions", models.ThresholdTwoThirds, 4, 2, 3, 9, 9, 4, true},
+
+		{"majority present", models.ThresholdMajorityPresent, 6, 0, 4, 12, 10, 6, true},
+		{"majority present abstentions count against", models.ThresholdMajorityPresent, 5, 0, 5, 12, 10, 6, false},
+		{"majority present nobody present", models.ThresholdMajorityPresent, 0, 0, 0, 12, 0, 1, false},
+
+		{"majority entire", models.ThresholdMajorityEntire, 5, 0, 0, 9, 6, 5, true},
+		{"majority entire unopposed but short", models.ThresholdMajorityEntire, 4, 0, 0, 9, 9, 5, false},
+		{"majority entire even membership", models.ThresholdMajorityEntire, 5, 5, 0, 10, 10, 6, false},
+
+		{"unanimous", models.ThresholdUnanimousConsent, 3, 0, 2, 9, 9, 3, true},
+		{"unanimous one nay", models.ThresholdUnanimousConsent, 8, 1, 0, 9, 9, 9, false},
+		{"unanimous all abstain", models.ThresholdUnanimousConsent, 0, 0, 3, 9, 9, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tally := EvaluateTally(models.VoteTally{
+				Ayes:        tt.ayes,
+				Nays:        tt.nays,
+				Abstentions: tt.abstentions,
+			}, tt.threshold, tt.membership, tt.present)
+
+			if tally.Required != tt.required {
+				t.Errorf("required %d, want %d", tally.Required, tt.required)
+			}
+			want := models.MotionStatusFailed
+			if tt.passed {
+				want = models.MotionStatusPassed
+			}
+			if tally.Result != want {
+				t.Errorf("result %s, want %s (%s)", tally.Result, want, tally.Rule)
+			}
+		})
+	}
+}
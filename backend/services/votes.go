@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidVote         = errors.New("vote must be aye, nay or abstain")
+	ErrVotingClosed        = errors.New("voting is not open on this motion")
+	ErrResultRequiresTally = errors.New("results are declared by closing the vote")
+	ErrInvalidDeadline     = errors.New("voting deadline must be in the future")
+)
+
+func ValidVoteResult(result models.VoteResult) bool {
+	switch result {
+	case models.VoteAye, models.VoteNay, models.VoteAbstain:
+		return true
+	}
+	return false
+}
+
+func CastVote(ctx context.Context, motionID, userID primitive.ObjectID, result models.VoteResult) (*models.Motion, *models.Vote, error) {
+	if !ValidVoteResult(result) {
+		return nil, nil, ErrInvalidVote
+	}
+
+	motion, err := GetMotion(ctx, motionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	committee, _, err := Authorize(ctx, motion.CommitteeID, userID, CapVote)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !votingOpen(motion, time.Now()) {
+		return nil, nil, ErrVotingClosed
+	}
+	if debateUnderway(motion, time.Now()) {
+		return nil, nil, ErrVotingNotStarted
+	}
+
+	settings, err := GetSettings(ctx, committee.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := requireSession(ctx, settings); err != nil {
+		return nil, nil, err
+	}
+	if err := requireQuorum(ctx, committee); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkImmediatelyPending(ctx, motion); err != nil {
+		return nil, nil, err
+	}
+
+	if IsSecretBallot(motion) {
+		if err := castSecretBallot(ctx, motion, userID, result); err != nil {
+			return nil, nil, err
+		}
+		// Only the fact of voting is logged; the choice stays secret.
+		if err := AuditCommittee(ctx, motion.CommitteeID, models.AuditBallotCast, &userID, &motion.ID, nil); err != nil {
+			return nil, nil, err
+		}
+		return motion, nil, nil
+	}
+
+	vote, err := upsertVote(ctx, motionID, userID, result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := AuditCommittee(ctx, motion.CommitteeID, models.AuditVoteCast, &userID, &motion.ID, map[string]string{
+		"result": string(vote.Result),
+	}); err != nil {
+		return nil, nil, err
+	}
+	return motion, vote, nil
+}
+
+// upsertVote records or changes a member's roll-call vote. Two first votes
+// racing each other both try to insert; the unique index turns the loser
+// away, and its retry finds the winner's record and updates it.
+func upsertVote(ctx context.Context, motionID, userID primitive.ObjectID, result models.VoteResult) (*models.Vote, error) {
+	for attempt := 0; ; attempt++ {
+		now := time.Now()
+		var vote models.Vote
+		err := config.GetCollection("votes").FindOneAndUpdate(ctx,
+			bson.M{"motion_id": motionID, "user_id": userID},
+			bson.M{
+				"$set": bson.M{
+					"result":     result,
+					"updated_at": now,
+				},
+				"$setOnInsert": bson.M{
+					"_id":        primitive.NewObjectID(),
+					"created_at": now,
+				},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&vote)
+		if mongo.IsDuplicateKeyError(err) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &vote, nil
+	}
+}
+
+// EnsureVoteIndexes gives each member at most one roll-call vote per motion.
+func EnsureVoteIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("votes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "motion_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func GetVotes(ctx context.Context, motionID primitive.ObjectID) ([]models.Vote, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := config.GetCollection("votes").Find(ctx, bson.M{"motion_id": motionID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	votes := []models.Vote{}
+	if err := cursor.All(ctx, &votes); err != nil {
+		return nil, err
+	}
+	return votes, nil
+}
+
+func ComputeTally(votes []models.Vote) models.VoteTally {
+	var tally models.VoteTally
+	for _, vote := range votes {
+		switch vote.Result {
+		case models.VoteAye:
+			tally.Ayes++
+		case models.VoteNay:
+			tally.Nays++
+		case models.VoteAbstain:
+			tally.Abstentions++
+		}
+	}
+	return tally
+}
+
+func CloseVoting(ctx context.Context, motionID, userID primitive.ObjectID) (*models.Motion, error) {
+	motion, err := GetMotion(ctx, motionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := Authorize(ctx, motion.CommitteeID, userID, CapCloseVoting); err != nil {
+		return nil, err
+	}
+
+	return closeVoting(ctx, motion, &userID)
+}
+
+func SetVotingDeadline(ctx context.Context, motionID, userID primitive.ObjectID, endsAt time.Time) (*models.Motion, error) {
+	motion, err := GetMotion(ctx, motionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := Authorize(ctx, motion.CommitteeID, userID, CapCloseVoting); err != nil {
+		return nil, err
+	}
+
+	if !endsAt.After(time.Now()) {
+		return nil, ErrInvalidDeadline
+	}
+
+	var updated models.Motion
+	err = config.GetCollection("motions").FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":    motionID,
+			"status": bson.M{"$in": []models.MotionStatus{models.MotionStatusSeconded, models.MotionStatusOpen}},
+		},
+		bson.M{"$set": bson.M{"voting_ends_at": endsAt, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrVotingClosed
+		}
+		return nil, err
+	}
+
+	deadlinesChanged(updated.ID)
+	return &updated, nil
+}
+
+func closeVoting(ctx context.Context, motion *models.Motion, closedBy *primitive.ObjectID) (*models.Motion, error) {
+	if motion.Status != models.MotionStatusOpen {
+		return nil, ErrVotingClosed
+	}
+
+	if err := checkImmediatelyPending(ctx, motion); err != nil {
+		return nil, err
+	}
+
+	committee, err := GetCommittee(ctx, motion.CommitteeID)
+	if err != nil {
+		return nil, err
+	}
+
+	counted, present, err := countVotes(ctx, committee, motion)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tally := EvaluateTally(counted, motion.Threshold, len(VotingMemberIDs(committee)), present)
+	tally.ClosedBy = closedBy
+	tally.DecidedAt = &now
+
+	// A passed motion's effect is applied after it is recorded as passed, so
+	// it is marked pending in the same write and the deadline scheduler
+	// retries it if applying fails here.
+	extra := bson.M{"tally": tally}
+	if tally.Result == models.MotionStatusPassed && adoptionEffect(motion) != nil {
+		extra["effect_pending"] = true
+	}
+	updated, err := transitionMotion(ctx, motion, tally.Result, extra, closedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if meeting, err := ActiveMeeting(ctx, updated.CommitteeID); err != nil {
+		log.Printf("Error loading active meeting: %v", err)
+	} else if meeting != nil {
+		if err := associateMotion(ctx, meeting, updated.ID); err != nil {
+			log.Printf("Error associating motion with meeting: %v", err)
+		}
+		updated.MeetingID = &meeting.ID
+	}
+
+	if updated.EffectPending {
+		if err := applyPendingEffect(ctx, updated); err != nil {
+			log.Printf("Error applying adopted motion %s, will retry: %v", updated.ID.Hex(), err)
+			deadlinesChanged(updated.ID)
+		}
+	}
+	return updated, nil
+}
+
+// adoptionEffect is what a motion does to other records once passed, or nil
+// if passing it is the whole of its effect.
+func adoptionEffect(motion *models.Motion) func(context.Context, *models.Motion) error {
+	switch {
+	case IsOverturnKind(motion.Kind):
+		return markOverturned
+	case motion.Kind == models.MotionKindAppeal:
+		return overturnRuling
+	case motion.Kind == models.MotionKindSuspendRules:
+		return applySuspension
+	case IsSubsidiaryKind(motion.Kind):
+		return applySubsidiary
+	case motion.Kind == models.MotionKindAdjourn, motion.Kind == models.MotionKindRecess:
+		return func(ctx context.Context, motion *models.Motion) error {
+			_, err := applyMeetingMotion(ctx, motion)
+			return err
+		}
+	case motion.Kind == models.MotionKindProcedure:
+		return applySettingsChange
+	case motion.Kind == models.MotionKindApproveMinutes:
+		return approveMinutes
+	}
+	return nil
+}
+
+// applyPendingEffect carries out a passed motion's effect and clears its
+// pending mark. An error the caller could not retry its way past, such as
+// the affected motion having moved on, is logged and clears the mark too;
+// anything else leaves it for the scheduler.
+func applyPendingEffect(ctx context.Context, motion *models.Motion) error {
+	if apply := adoptionEffect(motion); apply != nil {
+		if err := apply(ctx, motion); err != nil {
+			if ClassifyError(err) == ErrorInternal {
+				return err
+			}
+			log.Printf("Adopted motion %s cannot take effect: %v", motion.ID.Hex(), err)
+		}
+	}
+
+	if _, err := config.GetCollection("motions").UpdateOne(ctx,
+		bson.M{"_id": motion.ID},
+		bson.M{"$unset": bson.M{"effect_pending": ""}},
+	); err != nil {
+		return err
+	}
+	motion.EffectPending = false
+	return nil
+}
+
+// AdoptionEffects is what a just-passed motion changed besides itself.
+type AdoptionEffects struct {
+	Motion   *models.Motion
+	Meeting  *models.Meeting
+	Settings *models.CommitteeSettings
+	Minutes  *models.MinutesDraft
+}
+
+// Effects loads the decision or ruling a passed motion overturned, the motion
+// it suspended a rule for, the main motion it applied to, the meeting it
+// adjourned or recessed, the settings it changed, or the minutes it approved.
+func Effects(ctx context.Context, motion *models.Motion) (AdoptionEffects, error) {
+	var effects AdoptionEffects
+	if motion.Status != models.MotionStatusPassed {
+		return effects, nil
+	}
+
+	var err error
+	switch {
+	case (IsOverturnKind(motion.Kind) || motion.Kind == models.MotionKindAppeal ||
+		motion.Kind == models.MotionKindSuspendRules) && motion.RefMotionID != nil:
+		effects.Motion, err = GetMotion(ctx, *motion.RefMotionID)
+	case IsSubsidiaryKind(motion.Kind) && motion.ParentMotionID != nil:
+		effects.Motion, err = GetMotion(ctx, *motion.ParentMotionID)
+	case (motion.Kind == models.MotionKindAdjourn || motion.Kind == models.MotionKindRecess) && motion.MeetingID != nil:
+		effects.Meeting, err = GetMeeting(ctx, *motion.MeetingID)
+	case motion.Kind == models.MotionKindProcedure:
+		effects.Settings, err = GetSettings(ctx, motion.CommitteeID)
+	case motion.Kind == models.MotionKindApproveMinutes && motion.MinutesID != nil:
+		effects.Minutes, err = GetMinutesDraft(ctx, *motion.MinutesID)
+	}
+	return effects, err
+}
+
+// countVotes returns the raw count and how many members were present. During
+// a meeting that is the attendance roll; otherwise members who cast any
+// ballot (abstentions included) are treated as present.
+func countVotes(ctx context.Context, committee *models.Committee, motion *models.Motion) (models.VoteTally, int, error) {
+	tally, present, err := rawCount(ctx, motion)
+	if err != nil {
+		return models.VoteTally{}, 0, err
+	}
+
+	quorum, err := activeQuorum(ctx, committee)
+	if err != nil {
+		return models.VoteTally{}, 0, err
+	}
+	if quorum != nil && quorum.PresentCount > present {
+		present = quorum.PresentCount
+	}
+	return tally, present, nil
+}
+
+func rawCount(ctx context.Context, motion *models.Motion) (models.VoteTally, int, error) {
+	if IsSecretBallot(motion) {
+		return secretTally(ctx, motion.ID)
+	}
+
+	votes, err := GetVotes(ctx, motion.ID)
+	if err != nil {
+		return models.VoteTally{}, 0, err
+	}
+
+	voters := make(map[primitive.ObjectID]bool, len(votes))
+	for _, vote := range votes {
+		voters[vote.UserID] = true
+	}
+	return ComputeTally(votes), len(voters), nil
+}
+
+func votingOpen(motion *models.Motion, now time.Time) bool {
+	if motion.Status != models.MotionStatusOpen {
+		return false
+	}
+	return motion.VotingEndsAt == nil || now.Before(*motion.VotingEndsAt)
+}
+
+func CurrentTally(ctx context.Context, motionID primitive.ObjectID) (models.VoteTally, error) {
+	votes, err := GetVotes(ctx, motionID)
+	if err != nil {
+		return models.VoteTally{}, err
+	}
+	return ComputeTally(votes), nil
+}
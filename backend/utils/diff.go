@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/zach-short/final-web-programming/models"
+)
+
+// maxDiffCells bounds the longest common subsequence table. Past it the
+// changed span is shown as one deletion and one insertion.
+const maxDiffCells = 1 << 20
+
+// DiffWords compares two texts word by word using a longest common
+// subsequence, merging adjacent words with the same operation. Words the
+// texts share at either end are matched before the table is built, so a
+// small edit to a long text stays cheap.
+func DiffWords(before, after string) []models.DiffOp {
+	a := strings.Fields(before)
+	b := strings.Fields(after)
+
+	ops := []models.DiffOp{}
+	push := func(op models.DiffOpType, word string) {
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += " " + word
+			return
+		}
+		ops = append(ops, models.DiffOp{Op: op, Text: word})
+	}
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		push(models.DiffEqual, a[prefix])
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, word := range midA {
+			push(models.DiffDelete, word)
+		}
+		for _, word := range midB {
+			push(models.DiffInsert, word)
+		}
+	} else {
+		diffMiddle(midA, midB, push)
+	}
+
+	for _, word := range a[len(a)-suffix:] {
+		push(models.DiffEqual, word)
+	}
+	return ops
+}
+
+func diffMiddle(a, b []string, push func(models.DiffOpType, string)) {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			push(models.DiffEqual, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			push(models.DiffDelete, a[i])
+			i++
+		default:
+			push(models.DiffInsert, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		push(models.DiffDelete, a[i])
+	}
+	for ; j < len(b); j++ {
+		push(models.DiffInsert, b[j])
+	}
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zach-short/final-web-programming/models"
+)
+
+// applyOps rebuilds both texts from a diff: the original from its equal and
+// deleted words, the revision from its equal and inserted words.
+func applyOps(ops []models.DiffOp) (before, after string) {
+	var a, b []string
+	for _, op := range ops {
+		switch op.Op {
+		case models.DiffEqual:
+			a = append(a, op.Text)
+			b = append(b, op.Text)
+		case models.DiffDelete:
+			a = append(a, op.Text)
+		case models.DiffInsert:
+			b = append(b, op.Text)
+		}
+	}
+	return strings.Join(a, " "), strings.Join(b, " ")
+}
+
+func words(prefix string, n int) string {
+	w := make([]string, n)
+	for i := range w {
+		w[i] = prefix + strings.Repeat("x", i%7)
+	}
+	return strings.Join(w, " ")
+}
+
+func TestDiffWords(t *testing.T) {
+	long := words("a", 1200)
+
+	tests := []struct {
+		name          string
+		before, after string
+		ops           []models.DiffOp
+	}{
+		{"unchanged", "the motion carries", "the motion carries",
+			[]models.DiffOp{{Op: models.DiffEqual, Text: "the motion carries"}}},
+		{"insert", "buy chairs", "buy ten chairs",
+			[]models.DiffOp{
+				{Op: models.DiffEqual, Text: "buy"},
+				{Op: models.DiffInsert, Text: "ten"},
+				{Op: models.DiffEqual, Text: "chairs"},
+			}},
+		{"delete", "buy ten new chairs", "buy chairs",
+			[]models.DiffOp{
+				{Op: models.DiffEqual, Text: "buy"},
+				{Op: models.DiffDelete, Text: "ten new"},
+				{Op: models.DiffEqual, Text: "chairs"},
+			}},
+		{"replace", "buy ten chairs", "buy six chairs",
+			[]models.DiffOp{
+				{Op: models.DiffEqual, Text: "buy"},
+				{Op: models.DiffDelete, Text: "ten"},
+				{Op: models.DiffInsert, Text: "six"},
+				{Op: models.DiffEqual, Text: "chairs"},
+			}},
+		{"from empty", "", "new text",
+			[]models.DiffOp{{Op: models.DiffInsert, Text: "new text"}}},
+		{"to empty", "old text", "",
+			[]models.DiffOp{{Op: models.DiffDelete, Text: "old text"}}},
+		{"whitespace only", "a  b\nc", "a b c",
+			[]models.DiffOp{{Op: models.DiffEqual, Text: "a b c"}}},
+		{"over the table bound", "start " + long + " end", "start " + words("b", 1200) + " end", nil},
+		{"small edit to a long text", long + " end", long + " finish", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := DiffWords(tt.before, tt.after)
+
+			before, after := applyOps(ops)
+			if want := strings.Join(strings.Fields(tt.before), " "); before != want {
+				t.Errorf("ops rebuild the original as %.60q, want %.60q", before, want)
+			}
+			if want := strings.Join(strings.Fields(tt.after), " "); after != want {
+				t.Errorf("ops rebuild the revision as %.60q, want %.60q", after, want)
+			}
+
+			if tt.ops == nil {
+				return
+			}
+			if len(ops) != len(tt.ops) {
+				t.Fatalf("got %v, want %v", ops, tt.ops)
+			}
+			for i := range ops {
+				if ops[i] != tt.ops[i] {
+					t.Errorf("op %d is %v, want %v", i, ops[i], tt.ops[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDiffWordsFallsBackPastTableBound(t *testing.T) {
+	before := "start " + words("a", 1200) + " end"
+	after := "start " + words("b", 1200) + " end"
+	if n := 1200 * 1200; n <= maxDiffCells {
+		t.Fatalf("%d cells does not exceed the bound of %d", n, maxDiffCells)
+	}
+
+	want := []models.DiffOpType{models.DiffEqual, models.DiffDelete, models.DiffInsert, models.DiffEqual}
+	ops := DiffWords(before, after)
+	if len(ops) != len(want) {
+		t.Fatalf("got %d ops, want %d", len(ops), len(want))
+	}
+	for i, op := range ops {
+		if op.Op != want[i] {
+			t.Errorf("op %d is %s, want %s", i, op.Op, want[i])
+		}
+	}
+}
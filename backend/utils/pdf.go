@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pdfPageWidth  = 612.0
+	pdfPageHeight = 792.0
+	pdfMargin     = 72.0
+	pdfLineFactor = 1.35
+	pdfBoldFactor = 1.07
+)
+
+// Advance widths of the printable ASCII characters in Helvetica, in
+// thousandths of the font size, starting at the space.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// winAnsiSpecials maps the punctuation people paste most often onto its
+// WinAnsiEncoding byte.
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// PDF lays out plain text on US Letter pages. It only uses the standard
+// Helvetica fonts, which every reader has built in, so nothing is embedded
+// and no external tools are needed.
+type PDF struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func NewPDF() *PDF {
+	p := &PDF{}
+	p.newPage()
+	return p
+}
+
+func (p *PDF) newPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.y = pdfPageHeight - pdfMargin
+}
+
+func (p *PDF) page() *bytes.Buffer {
+	return p.pages[len(p.pages)-1]
+}
+
+// Text writes a paragraph, wrapping it to the page width. Line breaks in the
+// text start new lines.
+func (p *PDF) Text(text string, size float64, bold bool) {
+	p.TextIndent(text, size, bold, 0)
+}
+
+func (p *PDF) TextIndent(text string, size float64, bold bool, indent float64) {
+	width := pdfPageWidth - 2*pdfMargin - indent
+	for _, paragraph := range strings.Split(text, "\n") {
+		for _, line := range wrapPDFLine(paragraph, size, bold, width) {
+			p.line(line, size, bold, pdfMargin+indent)
+		}
+	}
+}
+
+func (p *PDF) line(text string, size float64, bold bool, x float64) {
+	height := size * pdfLineFactor
+	if p.y-height < pdfMargin {
+		p.newPage()
+	}
+	p.y -= height
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, p.y, escapePDFText(text))
+}
+
+// Space leaves a vertical gap, starting a new page if it would not fit.
+func (p *PDF) Space(points float64) {
+	if p.y-points < pdfMargin {
+		p.newPage()
+		return
+	}
+	p.y -= points
+}
+
+// Rule draws a thin line across the text column.
+func (p *PDF) Rule() {
+	p.Space(6)
+	fmt.Fprintf(p.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, p.y, pdfPageWidth-pdfMargin, p.y)
+	p.Space(6)
+}
+
+// Bytes numbers the pages and writes the finished document.
+func (p *PDF) Bytes() []byte {
+	var out bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range p.pages {
+		footer := fmt.Sprintf("Page %d of %d", i+1, len(p.pages))
+		x := pdfPageWidth - pdfMargin - textWidth(footer, 9, false)
+		content := page.String() + fmt.Sprintf("BT /F1 9.0 Tf %.2f %.2f Td (%s) Tj ET\n", x, pdfMargin/2, footer)
+
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, len(offsets)))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+func charWidth(r rune) int {
+	if r >= ' ' && r <= '~' {
+		return helveticaWidths[r-' ']
+	}
+	return 556
+}
+
+func textWidth(text string, size float64, bold bool) float64 {
+	total := 0
+	for _, r := range text {
+		total += charWidth(r)
+	}
+	width := float64(total) * size / 1000
+	if bold {
+		width *= pdfBoldFactor
+	}
+	return width
+}
+
+// wrapPDFLine breaks text into lines no wider than width, splitting words
+// that are too long to fit on a line of their own.
+func wrapPDFLine(text string, size float64, bold bool, width float64) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+
+	var lines []string
+	current := ""
+	for _, word := range words {
+		for textWidth(word, size, bold) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			runes := []rune(word)
+			cut := len(runes)
+			for cut > 1 && textWidth(string(runes[:cut]), size, bold) > width {
+				cut--
+			}
+			lines = append(lines, string(runes[:cut]))
+			word = string(runes[cut:])
+		}
+
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if textWidth(candidate, size, bold) > width {
+			lines = append(lines, current)
+			current = word
+		} else {
+			current = candidate
+		}
+	}
+	return append(lines, current)
+}
+
+// escapePDFText encodes text as WinAnsi for a PDF string literal. Characters
+// the encoding lacks become question marks.
+func escapePDFText(text string) string {
+	var out strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r >= ' ' && r <= '~':
+			out.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&out, "\\%03o", r)
+		default:
+			if b, ok := winAnsiSpecials[r]; ok {
+				fmt.Fprintf(&out, "\\%03o", b)
+			} else {
+				out.WriteByte('?')
+			}
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,265 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Broker carries room events between the hubs of every backend instance.
+// Each hub publishes what it broadcasts and delivers whatever it receives to
+// its own clients, including the events it published itself, so every
+// instance sends a room's events in the same order. The broker also numbers
+// each room's broadcasts, so a cursor issued by one instance can be resumed
+// on any other.
+type Broker interface {
+	// Publish sends an event that is acted on live but not numbered, such
+	// as a change in presence.
+	Publish(ctx context.Context, data []byte) error
+	// PublishToRoom numbers the event in the room's stream as it publishes
+	// it, so the numbers reach every subscriber in order.
+	PublishToRoom(ctx context.Context, roomID string, data []byte) error
+	// Position is the room's epoch and the number of its latest event. It
+	// starts the room's stream if it has none yet.
+	Position(ctx context.Context, roomID string) (Stamp, error)
+	// Subscribe calls handler with every event published after it returns,
+	// until ctx is done. Events reach each subscriber in publish order, with
+	// their stamp if they were published to a room.
+	Subscribe(ctx context.Context, handler func(data []byte, stamp *Stamp)) error
+	Close() error
+}
+
+// Stamp places an event in its room's stream. A room's numbers start over
+// only in a new epoch.
+type Stamp struct {
+	Epoch string `json:"epoch"`
+	Seq   uint64 `json:"seq"`
+}
+
+func newEpoch() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// MemoryBroker connects hubs in the same process. It is the broker for a
+// single instance, and lets several hubs be run side by side locally.
+type MemoryBroker struct {
+	mutex       sync.Mutex
+	subscribers map[int]func([]byte, *Stamp)
+	next        int
+	streams     map[string]*Stamp
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		subscribers: make(map[int]func([]byte, *Stamp)),
+		streams:     make(map[string]*Stamp),
+	}
+}
+
+// Publish delivers synchronously, so the mutex is held throughout to keep
+// concurrent publishers from reaching subscribers in different orders.
+func (b *MemoryBroker) Publish(ctx context.Context, data []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.send(data, nil)
+	return nil
+}
+
+// PublishToRoom numbers the event under the same lock it delivers it with.
+func (b *MemoryBroker) PublishToRoom(ctx context.Context, roomID string, data []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	stream := b.stream(roomID)
+	stream.Seq++
+	stamp := *stream
+	b.send(data, &stamp)
+	return nil
+}
+
+func (b *MemoryBroker) Position(ctx context.Context, roomID string) (Stamp, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return *b.stream(roomID), nil
+}
+
+// stream is called with the mutex held.
+func (b *MemoryBroker) stream(roomID string) *Stamp {
+	stream, ok := b.streams[roomID]
+	if !ok {
+		stream = &Stamp{Epoch: newEpoch()}
+		b.streams[roomID] = stream
+	}
+	return stream
+}
+
+// send is called with the mutex held.
+func (b *MemoryBroker) send(data []byte, stamp *Stamp) {
+	for _, handler := range b.subscribers {
+		handler(data, stamp)
+	}
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, handler func([]byte, *Stamp)) error {
+	b.mutex.Lock()
+	id := b.next
+	b.next++
+	b.subscribers[id] = handler
+	b.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mutex.Lock()
+		delete(b.subscribers, id)
+		b.mutex.Unlock()
+	}()
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.subscribers = make(map[int]func([]byte, *Stamp))
+	return nil
+}
+
+// RedisBroker connects hubs on different instances through one Redis pub/sub
+// channel. Each room's epoch and latest number are kept in Redis beside it.
+type RedisBroker struct {
+	client  *redis.Client
+	channel string
+}
+
+const (
+	redisChannel = "ceros:ws:rooms"
+
+	// redisStreamTTL is how long a quiet room keeps its epoch and numbers.
+	// After that its next event starts a new epoch and clients resync.
+	redisStreamTTL = 24 * time.Hour
+)
+
+// redisEnvelope is what goes over the channel: the event and, for room
+// broadcasts, the stamp Redis gave it.
+type redisEnvelope struct {
+	Stamp *Stamp          `json:"stamp,omitempty"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// redisStream reads a room's epoch and latest number, starting a new epoch
+// if the room has none. KEYS are the epoch and sequence keys; ARGV[1] is the
+// epoch to start with.
+const redisStream = `
+local epoch = redis.call('GET', KEYS[1])
+if not epoch then
+	epoch = ARGV[1]
+	redis.call('SET', KEYS[1], epoch)
+	redis.call('SET', KEYS[2], 0)
+end
+`
+
+// redisPublishToRoom numbers an event and publishes it in one step, which
+// Redis runs without interleaving any other client's, so the numbers go out
+// in order. ARGV[2] is the channel, ARGV[3] the event and ARGV[4] the TTL.
+var redisPublishToRoom = redis.NewScript(redisStream + `
+local seq = redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+redis.call('PUBLISH', ARGV[2], '{"stamp":{"epoch":"' .. epoch .. '","seq":' .. tostring(seq) .. '},"data":' .. ARGV[3] .. '}')
+return seq
+`)
+
+var redisPosition = redis.NewScript(redisStream + `
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+return {epoch, redis.call('GET', KEYS[2])}
+`)
+
+func NewRedisBroker(ctx context.Context, url string) (*RedisBroker, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parsing redis URL: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to redis: %w", err)
+	}
+	return &RedisBroker{client: client, channel: redisChannel}, nil
+}
+
+// streamKeys names a room's epoch and sequence keys. The braces keep both in
+// one hash slot, as a script needs on Redis Cluster.
+func streamKeys(roomID string) []string {
+	return []string{"ceros:ws:epoch:{" + roomID + "}", "ceros:ws:seq:{" + roomID + "}"}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, data []byte) error {
+	envelope, err := json.Marshal(redisEnvelope{Data: data})
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, envelope).Err()
+}
+
+func (b *RedisBroker) PublishToRoom(ctx context.Context, roomID string, data []byte) error {
+	ttl := int(redisStreamTTL / time.Second)
+	return redisPublishToRoom.Run(ctx, b.client, streamKeys(roomID), newEpoch(), b.channel, string(data), ttl).Err()
+}
+
+func (b *RedisBroker) Position(ctx context.Context, roomID string) (Stamp, error) {
+	ttl := int(redisStreamTTL / time.Second)
+	values, err := redisPosition.Run(ctx, b.client, streamKeys(roomID), newEpoch(), ttl).StringSlice()
+	if err != nil {
+		return Stamp{}, err
+	}
+	if len(values) != 2 {
+		return Stamp{}, fmt.Errorf("reading the position of room %s: got %d values", roomID, len(values))
+	}
+	seq, err := strconv.ParseUint(values[1], 10, 64)
+	if err != nil {
+		return Stamp{}, fmt.Errorf("reading the position of room %s: %w", roomID, err)
+	}
+	return Stamp{Epoch: values[0], Seq: seq}, nil
+}
+
+// Subscribe waits for Redis to confirm the subscription before returning.
+// The subscription reconnects on its own if the connection drops, though
+// events published while it is down are lost to this instance; the gap in
+// numbers keeps cursors from replaying across it.
+func (b *RedisBroker) Subscribe(ctx context.Context, handler func([]byte, *Stamp)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("subscribing to %s: %w", b.channel, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		pubsub.Close()
+	}()
+	go func() {
+		for message := range pubsub.Channel() {
+			var envelope redisEnvelope
+			if err := json.Unmarshal([]byte(message.Payload), &envelope); err != nil {
+				log.Printf("Error decoding broker message: %v", err)
+				continue
+			}
+			handler(envelope.Data, envelope.Stamp)
+		}
+	}()
+	return nil
+}
+
+func (b *RedisBroker) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,194 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testRoom = "group_test"
+
+// newTestHubs starts n hubs sharing one in-process broker, the way several
+// instances share Redis.
+func newTestHubs(t *testing.T, n int) []*Hub {
+	t.Helper()
+
+	broker := NewMemoryBroker()
+	t.Cleanup(func() { broker.Close() })
+
+	hubs := make([]*Hub, n)
+	for i := range hubs {
+		h, err := NewHub(broker)
+		if err != nil {
+			t.Fatalf("NewHub: %v", err)
+		}
+		hubs[i] = h
+	}
+	return hubs
+}
+
+// newTestClient connects a client to h without a socket. It is hidden so
+// joining does not announce presence, which would need the database and add
+// events to the room.
+func newTestClient(h *Hub) *Client {
+	client := &Client{
+		hub:      h,
+		send:     make(chan []byte, 16),
+		userID:   primitive.NewObjectID(),
+		rooms:    make(map[string]bool),
+		protocol: models.ProtocolVersion,
+		hidden:   true,
+	}
+
+	h.mutex.Lock()
+	h.clients[client] = true
+	h.mutex.Unlock()
+	return client
+}
+
+func receive(t *testing.T, client *Client) models.WSMessage {
+	t.Helper()
+
+	select {
+	case data := <-client.send:
+		var message models.WSMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("decoding frame: %v", err)
+		}
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("no frame received")
+		return models.WSMessage{}
+	}
+}
+
+func broadcast(h *Hub, action string) {
+	h.BroadcastToRoom(testRoom, models.WSMessage{
+		Action:  action,
+		Type:    models.TypeGroup,
+		Payload: map[string]any{"content": action},
+	})
+}
+
+func TestBroadcastReachesOtherHub(t *testing.T) {
+	hubs := newTestHubs(t, 2)
+	sender := newTestClient(hubs[0])
+	listener := newTestClient(hubs[1])
+	hubs[0].JoinRoom(sender, testRoom)
+	hubs[1].JoinRoom(listener, testRoom)
+
+	broadcast(hubs[0], "new_message")
+
+	for _, client := range []*Client{sender, listener} {
+		message := receive(t, client)
+		if message.Action != "new_message" || message.RoomID != testRoom {
+			t.Errorf("got %s in %q, want new_message in %q", message.Action, message.RoomID, testRoom)
+		}
+	}
+}
+
+func TestHubsSequenceRoomEventsAlike(t *testing.T) {
+	hubs := newTestHubs(t, 2)
+	clients := []*Client{newTestClient(hubs[0]), newTestClient(hubs[1])}
+	for i, client := range clients {
+		hubs[i].JoinRoom(client, testRoom)
+	}
+
+	actions := []string{"first", "second", "third"}
+	for i, action := range actions {
+		broadcast(hubs[i%2], action)
+	}
+
+	for i, client := range clients {
+		for j, action := range actions {
+			message := receive(t, client)
+			if message.Action != action || message.Seq != uint64(j+1) {
+				t.Errorf("hub %d: got %s #%d, want %s #%d", i, message.Action, message.Seq, action, j+1)
+			}
+		}
+		if cursor := hubs[i].JoinRoom(client, testRoom); cursor.Seq != uint64(len(actions)) {
+			t.Errorf("hub %d: cursor at %d, want %d", i, cursor.Seq, len(actions))
+		}
+	}
+}
+
+func TestResumeAcrossHubs(t *testing.T) {
+	hubs := newTestHubs(t, 2)
+	first := newTestClient(hubs[0])
+	other := newTestClient(hubs[1])
+	cursor := hubs[0].JoinRoom(first, testRoom)
+	hubs[1].JoinRoom(other, testRoom)
+
+	broadcast(hubs[1], "missed_one")
+	broadcast(hubs[0], "missed_two")
+
+	// A cursor issued by one hub replays on another, since the broker
+	// numbers the room's events for both.
+	moved := newTestClient(hubs[1])
+	result := hubs[1].ResumeRoom(moved, cursor)
+	if result.Resync || result.Replayed != 2 {
+		t.Fatalf("other hub: resync=%v replayed=%d, want a replay of 2", result.Resync, result.Replayed)
+	}
+	for j, action := range []string{"missed_one", "missed_two"} {
+		if message := receive(t, moved); message.Action != action || message.Seq != uint64(j+1) {
+			t.Errorf("other hub: got %s #%d, want %s #%d", message.Action, message.Seq, action, j+1)
+		}
+	}
+	if result.Epoch != cursor.Epoch || result.Seq != 2 {
+		t.Errorf("other hub: cursor at %s #%d, want %s #2", result.Epoch, result.Seq, cursor.Epoch)
+	}
+}
+
+func TestResumeFromUnknownEpochResyncs(t *testing.T) {
+	hubs := newTestHubs(t, 1)
+	listener := newTestClient(hubs[0])
+	hubs[0].JoinRoom(listener, testRoom)
+	broadcast(hubs[0], "new_message")
+
+	client := newTestClient(hubs[0])
+	result := hubs[0].ResumeRoom(client, models.RoomCursor{RoomID: testRoom, Epoch: "gone", Seq: 0})
+	if !result.Resync {
+		t.Fatalf("replayed %d events from a cursor in another epoch", result.Replayed)
+	}
+	if message := receive(t, client); message.Action != "resync_required" {
+		t.Errorf("got %s, want resync_required", message.Action)
+	}
+	if result.Seq != 1 {
+		t.Errorf("cursor at %d, want 1", result.Seq)
+	}
+}
+
+// A hub that starts after a room has had events only remembers what it has
+// received since, so it replays a cursor from after it started but not one
+// from before.
+func TestLateHubReplaysOnlyWhatItReceived(t *testing.T) {
+	broker := NewMemoryBroker()
+	t.Cleanup(func() { broker.Close() })
+
+	early, err := NewHub(broker)
+	if err != nil {
+		t.Fatalf("NewHub: %v", err)
+	}
+	before := early.JoinRoom(newTestClient(early), testRoom)
+	broadcast(early, "before_start")
+
+	late, err := NewHub(broker)
+	if err != nil {
+		t.Fatalf("NewHub: %v", err)
+	}
+	after := late.JoinRoom(newTestClient(late), testRoom)
+	if after.Epoch != before.Epoch || after.Seq != 1 {
+		t.Fatalf("late hub: cursor at %s #%d, want %s #1", after.Epoch, after.Seq, before.Epoch)
+	}
+	broadcast(early, "after_start")
+
+	if result := late.ResumeRoom(newTestClient(late), after); result.Resync || result.Replayed != 1 {
+		t.Errorf("cursor from after start: resync=%v replayed=%d, want a replay of 1", result.Resync, result.Replayed)
+	}
+	if result := late.ResumeRoom(newTestClient(late), before); !result.Resync {
+		t.Errorf("cursor from before start: replayed %d events the hub never received", result.Replayed)
+	}
+}
@@ -3,6 +3,7 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/zach-short/final-web-programming/config"
 	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -26,6 +28,7 @@ const (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    subprotocols,
 	CheckOrigin: func(r *http.Request) bool {
 		origin := r.Header.Get("Origin")
 		return origin == "http://localhost:3000" || origin == "https://ceros.netlify.app"
@@ -36,14 +39,20 @@ type WebSocketConn struct {
 	*websocket.Conn
 }
 
+// NewClient wraps an upgraded connection and queues the welcome frame that
+// tells the client which protocol version was negotiated.
 func NewClient(hub *Hub, conn *websocket.Conn, userID primitive.ObjectID) *Client {
-	return &Client{
-		hub:    hub,
-		conn:   &WebSocketConn{conn},
-		send:   make(chan []byte, 256),
-		userID: userID,
-		rooms:  make(map[string]bool),
-	}
+	client := &Client{
+		hub:      hub,
+		conn:     &WebSocketConn{conn},
+		send:     make(chan []byte, 256),
+		userID:   userID,
+		rooms:    make(map[string]bool),
+		protocol: negotiatedVersion(conn.Subprotocol()),
+		hidden:   !showsOnlineStatus(userID),
+	}
+	client.sendWelcome()
+	return client
 }
 
 func (c *Client) ReadPump() {
@@ -68,13 +77,13 @@ func (c *Client) ReadPump() {
 			break
 		}
 
-		var wsMsg models.WSMessage
-		if err := json.Unmarshal(message, &wsMsg); err != nil {
-			log.Printf("Error unmarshaling message: %v", err)
+		var req models.WSRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			c.reject(req, invalidPayload("malformed frame: %v", err))
 			continue
 		}
 
-		c.handleMessage(wsMsg)
+		c.handleRequest(req)
 	}
 }
 
@@ -119,63 +128,101 @@ func (c *Client) WritePump() {
 	}
 }
 
-func (c *Client) handleMessage(wsMsg models.WSMessage) {
-	switch wsMsg.Action {
-	case "join_room":
-		if roomID, ok := wsMsg.Payload.(string); ok {
-			c.hub.JoinRoom(c, roomID)
-		}
+func (c *Client) handleJoinRoom(req models.WSRequest) (any, error) {
+	var p roomPayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
+	}
+	if err := c.authorizeRoom(p.RoomID, services.CapViewCommittee); err != nil {
+		return nil, err
+	}
+	cursor := c.hub.JoinRoom(c, p.RoomID)
 
-	case "leave_room":
-		if roomID, ok := wsMsg.Payload.(string); ok {
-			c.hub.LeaveRoom(c, roomID)
-		}
+	return map[string]any{
+		"roomId":  p.RoomID,
+		"epoch":   cursor.Epoch,
+		"seq":     cursor.Seq,
+		"canPost": c.authorizeRoom(p.RoomID, services.CapDebate) == nil,
+	}, nil
+}
 
-	case "send_message":
-		c.handleSendMessage(wsMsg)
+// handleResume rejoins the rooms a reconnecting client was in and replays
+// what it missed in each. A room the client may no longer view is reported
+// in the result instead of failing the whole resume.
+func (c *Client) handleResume(req models.WSRequest) (any, error) {
+	var p resumePayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
+	}
 
-	case "reply_to_message":
-		c.handleReplyToMessage(wsMsg)
+	rooms := make([]models.WSResume, 0, len(p.Rooms))
+	denied := map[string]models.WSError{}
+	for _, last := range p.Rooms {
+		if err := c.authorizeRoom(last.RoomID, services.CapViewCommittee); err != nil {
+			denied[last.RoomID] = models.WSError{Action: req.Action, Code: ErrorCode(err), Error: err.Error()}
+			continue
+		}
+		resume := c.hub.ResumeRoom(c, last)
+		resume.Presence = c.hub.RoomPresence(last.RoomID)
+		rooms = append(rooms, resume)
+	}
 
-	case "propose_motion":
-		c.handleProposeMotion(wsMsg)
+	return map[string]any{
+		"rooms":  rooms,
+		"denied": denied,
+	}, nil
+}
 
-	case "second_motion":
-		c.handleSecondMotion(wsMsg)
+func (c *Client) handleLeaveRoom(req models.WSRequest) (any, error) {
+	var p roomPayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
+	}
+	c.hub.LeaveRoom(c, p.RoomID)
+	return map[string]any{"roomId": p.RoomID}, nil
+}
 
-	case "vote_motion":
-		c.handleVoteMotion(wsMsg)
+// handleTyping lets the room know the sender is typing. Repeated frames
+// while the indicator is up only keep it up; it drops after typingTTL without
+// one, or as soon as the sender posts or says they stopped.
+func (c *Client) handleTyping(req models.WSRequest) (any, error) {
+	var p typingPayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
+	}
 
-	default:
-		log.Printf("Unknown action: %s", wsMsg.Action)
+	if !c.hub.inRoom(c, p.RoomID) {
+		return nil, errNotInRoom
+	}
+	if err := c.authorizeRoom(p.RoomID, services.CapDebate); err != nil {
+		return nil, err
 	}
-}
 
-func (c *Client) handleSendMessage(wsMsg models.WSMessage) {
-	payload, ok := wsMsg.Payload.(map[string]any)
-	if !ok {
-		log.Printf("Invalid message payload")
-		return
+	typing := p.Typing == nil || *p.Typing
+	if typing {
+		c.hub.startTyping(c, p.RoomID)
+	} else {
+		c.hub.stopTyping(typingKey{roomID: p.RoomID, userID: c.userID})
 	}
+	return map[string]any{"roomId": p.RoomID, "typing": typing}, nil
+}
 
-	content, ok := payload["content"].(string)
-	if !ok {
-		log.Printf("Invalid message content")
-		return
+func (c *Client) handleSendMessage(req models.WSRequest) (any, error) {
+	var p messagePayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
 	}
 
-	roomID, ok := payload["roomId"].(string)
-	if !ok {
-		log.Printf("Invalid room ID")
-		return
+	if err := c.authorizeRoom(p.RoomID, services.CapDebate); err != nil {
+		return nil, err
 	}
 
 	message := models.Message{
 		ID:        primitive.NewObjectID(),
-		Type:      wsMsg.Type,
+		Type:      req.Type,
 		SenderID:  c.userID,
-		Content:   content,
-		RoomID:    roomID,
+		Content:   p.Content,
+		RoomID:    p.RoomID,
 		Timestamp: time.Now(),
 	}
 
@@ -185,82 +232,38 @@ func (c *Client) handleSendMessage(wsMsg models.WSMessage) {
 
 	_, err := collection.InsertOne(ctx, message)
 	if err != nil {
-		log.Printf("Failed to save message to database: %v", err)
-		return
-	}
-
-	log.Printf("Message saved: %s in room %s", content, roomID)
-
-	usersCollection := config.DB.Database(os.Getenv("DATABASE_NAME")).Collection("users")
-	var sender struct {
-		ID      primitive.ObjectID `bson:"_id" json:"id"`
-		Name    string             `bson:"name" json:"name"`
-		Picture string             `bson:"picture" json:"picture"`
+		return nil, fmt.Errorf("saving message: %w", err)
 	}
 
-	projection := bson.M{"_id": 1, "name": 1, "picture": 1}
-	err = usersCollection.FindOne(ctx, bson.M{"_id": c.userID}, options.FindOne().SetProjection(projection)).Decode(&sender)
-	if err != nil {
-		log.Printf("Failed to fetch sender user data: %v", err)
-		broadcastMsg := models.WSMessage{
-			Action:  "new_message",
-			Type:    message.Type,
-			Payload: message,
-		}
-		c.hub.BroadcastToRoom(roomID, broadcastMsg)
-		return
-	}
+	log.Printf("Message saved: %s in room %s", p.Content, p.RoomID)
 
-	broadcastMsg := models.WSMessage{
-		Action: "new_message",
-		Type:   message.Type,
-		Payload: map[string]any{
-			"message": message,
-			"sender":  sender,
-		},
-	}
+	c.hub.stopTyping(typingKey{roomID: p.RoomID, userID: c.userID})
 
-	c.hub.BroadcastToRoom(roomID, broadcastMsg)
+	c.broadcastMessage(ctx, "new_message", message)
+	return map[string]any{"message": message}, nil
 }
 
-func (c *Client) handleReplyToMessage(wsMsg models.WSMessage) {
-	payload, ok := wsMsg.Payload.(map[string]any)
-	if !ok {
-		log.Printf("Invalid reply payload")
-		return
-	}
-
-	content, ok := payload["content"].(string)
-	if !ok {
-		log.Printf("Invalid reply content")
-		return
+func (c *Client) handleReplyToMessage(req models.WSRequest) (any, error) {
+	var p replyPayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
 	}
 
-	roomID, ok := payload["roomId"].(string)
-	if !ok {
-		log.Printf("Invalid room ID")
-		return
+	if err := c.authorizeRoom(p.RoomID, services.CapDebate); err != nil {
+		return nil, err
 	}
 
-	parentMessageIDStr, ok := payload["parentMessageId"].(string)
-	if !ok {
-		log.Printf("Invalid parent message ID")
-		return
-	}
-
-	parentMessageID, err := primitive.ObjectIDFromHex(parentMessageIDStr)
-	if err != nil {
-		log.Printf("Invalid parent message ID format")
-		return
+	if err := c.checkParentMessage(p.ParentMessageID, p.RoomID); err != nil {
+		return nil, err
 	}
 
 	message := models.Message{
 		ID:              primitive.NewObjectID(),
 		Type:            models.TypeReply,
 		SenderID:        c.userID,
-		Content:         content,
-		RoomID:          roomID,
-		ParentMessageID: &parentMessageID,
+		Content:         p.Content,
+		RoomID:          p.RoomID,
+		ParentMessageID: &p.ParentMessageID,
 		Timestamp:       time.Now(),
 	}
 
@@ -268,21 +271,28 @@ func (c *Client) handleReplyToMessage(wsMsg models.WSMessage) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err = collection.InsertOne(ctx, message)
+	_, err := collection.InsertOne(ctx, message)
 	if err != nil {
-		log.Printf("Failed to save reply to database: %v", err)
-		return
+		return nil, fmt.Errorf("saving reply: %w", err)
 	}
 
-	parentCollection := config.DB.Database(os.Getenv("DATABASE_NAME")).Collection("messages")
-	_, err = parentCollection.UpdateOne(ctx,
-		bson.M{"_id": parentMessageID},
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"_id": p.ParentMessageID},
 		bson.M{"$inc": bson.M{"threadCount": 1}},
 	)
 	if err != nil {
 		log.Printf("Failed to update thread count: %v", err)
 	}
 
+	c.hub.stopTyping(typingKey{roomID: p.RoomID, userID: c.userID})
+
+	c.broadcastMessage(ctx, "new_reply", message)
+	return map[string]any{"message": message}, nil
+}
+
+// broadcastMessage sends a new message to its room along with the sender's
+// name and picture, or bare if the sender cannot be loaded.
+func (c *Client) broadcastMessage(ctx context.Context, action string, message models.Message) {
 	usersCollection := config.DB.Database(os.Getenv("DATABASE_NAME")).Collection("users")
 	var sender struct {
 		ID      primitive.ObjectID `bson:"_id" json:"id"`
@@ -291,149 +301,196 @@ func (c *Client) handleReplyToMessage(wsMsg models.WSMessage) {
 	}
 
 	projection := bson.M{"_id": 1, "name": 1, "picture": 1}
-	err = usersCollection.FindOne(ctx, bson.M{"_id": c.userID}, options.FindOne().SetProjection(projection)).Decode(&sender)
+	err := usersCollection.FindOne(ctx, bson.M{"_id": c.userID}, options.FindOne().SetProjection(projection)).Decode(&sender)
 	if err != nil {
-		log.Printf("Failed to fetch sender user data for reply: %v", err)
-		broadcastMsg := models.WSMessage{
-			Action:  "new_reply",
+		log.Printf("Failed to fetch sender user data: %v", err)
+		c.hub.BroadcastToRoom(message.RoomID, models.WSMessage{
+			Action:  action,
 			Type:    message.Type,
 			Payload: message,
-		}
-		c.hub.BroadcastToRoom(roomID, broadcastMsg)
+		})
 		return
 	}
 
-	broadcastMsg := models.WSMessage{
-		Action: "new_reply",
+	c.hub.BroadcastToRoom(message.RoomID, models.WSMessage{
+		Action: action,
 		Type:   message.Type,
 		Payload: map[string]any{
 			"message": message,
 			"sender":  sender,
 		},
+	})
+}
+
+func (c *Client) handleProposeMotion(req models.WSRequest) (any, error) {
+	var p proposeMotionPayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
 	}
 
-	c.hub.BroadcastToRoom(roomID, broadcastMsg)
-}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-func (c *Client) handleProposeMotion(wsMsg models.WSMessage) {
-	payload, ok := wsMsg.Payload.(map[string]any)
-	if !ok {
-		log.Printf("Invalid motion proposal payload")
-		return
+	committee, err := services.GetCommittee(ctx, p.CommitteeID)
+	if err != nil {
+		return nil, err
 	}
 
-	title, ok := payload["title"].(string)
-	if !ok {
-		log.Printf("Invalid motion title")
-		return
+	motion, err := services.ProposeMotion(ctx, committee, c.userID, p.input())
+	if err != nil {
+		return nil, err
 	}
 
-	description, ok := payload["description"].(string)
-	if !ok {
-		log.Printf("Invalid motion description")
-		return
+	c.hub.BroadcastMotionEvent("motion_proposed", motion, "")
+	return map[string]any{"motion": motion}, nil
+}
+
+func (c *Client) handleSecondMotion(req models.WSRequest) (any, error) {
+	var p motionPayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
 	}
 
-	roomID, ok := payload["roomId"].(string)
-	if !ok {
-		log.Printf("Invalid room ID")
-		return
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	motion, err := services.SecondMotion(ctx, p.MotionID, c.userID)
+	if err != nil {
+		return nil, err
 	}
 
-	committeeIDStr, ok := payload["committeeId"].(string)
-	if !ok {
-		log.Printf("Invalid committee ID")
-		return
+	c.hub.BroadcastMotionEvent("motion_seconded", motion, models.MotionStatusProposed)
+	return map[string]any{"motion": motion}, nil
+}
+
+func (c *Client) handleChangeMotionStatus(req models.WSRequest) (any, error) {
+	var p motionStatusPayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
 	}
 
-	committeeID, err := primitive.ObjectIDFromHex(committeeIDStr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	motion, previous, err := services.ChangeMotionStatus(ctx, p.MotionID, c.userID, p.Status)
 	if err != nil {
-		log.Printf("Invalid committee ID format")
-		return
+		return nil, err
 	}
 
-	broadcastMsg := models.WSMessage{
-		Action: "motion_proposed",
-		Type:   models.TypeMotion,
-		Payload: map[string]any{
-			"title":       title,
-			"description": description,
-			"moverID":     c.userID,
-			"committeeID": committeeID,
-			"status":      "proposed",
-		},
+	c.hub.BroadcastMotionEvent("motion_status_changed", motion, previous)
+	return map[string]any{"motion": motion}, nil
+}
+
+func (c *Client) handleVoteMotion(req models.WSRequest) (any, error) {
+	var p votePayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
 	}
 
-	c.hub.BroadcastToRoom(roomID, broadcastMsg)
-}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-func (c *Client) handleSecondMotion(wsMsg models.WSMessage) {
-	payload, ok := wsMsg.Payload.(map[string]any)
-	if !ok {
-		log.Printf("Invalid second motion payload")
-		return
+	motion, vote, err := services.CastVote(ctx, p.MotionID, c.userID, p.Vote)
+	if err != nil {
+		return nil, err
 	}
 
-	motionIDStr, ok := payload["motionId"].(string)
-	if !ok {
-		log.Printf("Invalid motion ID")
-		return
+	if vote == nil {
+		ballotsCast, err := services.BallotsCast(ctx, p.MotionID)
+		if err != nil {
+			log.Printf("Error counting ballots: %v", err)
+		}
+		c.hub.BroadcastSecretBallot(motion, ballotsCast)
+		return map[string]any{"motionId": motion.ID}, nil
 	}
 
-	roomID, ok := payload["roomId"].(string)
-	if !ok {
-		log.Printf("Invalid room ID")
-		return
+	tally, err := services.CurrentTally(ctx, p.MotionID)
+	if err != nil {
+		log.Printf("Error computing tally: %v", err)
 	}
 
-	broadcastMsg := models.WSMessage{
-		Action: "motion_seconded",
-		Type:   models.TypeMotion,
-		Payload: map[string]any{
-			"motionId":   motionIDStr,
-			"seconderId": c.userID,
-		},
+	c.hub.BroadcastVote(motion, vote, tally)
+	return map[string]any{"motionId": motion.ID, "vote": vote.Result}, nil
+}
+
+func (c *Client) handleCloseVoting(req models.WSRequest) (any, error) {
+	var p motionPayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	motion, err := services.CloseVoting(ctx, p.MotionID, c.userID)
+	if err != nil {
+		return nil, err
 	}
 
-	c.hub.BroadcastToRoom(roomID, broadcastMsg)
+	effects, err := services.Effects(ctx, motion)
+	if err != nil {
+		log.Printf("Error loading motion effects: %v", err)
+	}
+	c.hub.BroadcastVotingClosed(motion, effects)
+	return map[string]any{"motion": motion}, nil
 }
 
-func (c *Client) handleVoteMotion(wsMsg models.WSMessage) {
-	payload, ok := wsMsg.Payload.(map[string]any)
-	if !ok {
-		log.Printf("Invalid vote payload")
-		return
+func (c *Client) handleRuleOnMotion(req models.WSRequest) (any, error) {
+	var p rulingPayload
+	if err := decodePayload(req, &p); err != nil {
+		return nil, err
 	}
 
-	motionIDStr, ok := payload["motionId"].(string)
-	if !ok {
-		log.Printf("Invalid motion ID")
-		return
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	motion, err := services.RuleOnMotion(ctx, p.MotionID, c.userID, p.Decision, p.Explanation)
+	if err != nil {
+		return nil, err
 	}
 
-	voteResult, ok := payload["vote"].(string)
-	if !ok {
-		log.Printf("Invalid vote result")
+	c.hub.BroadcastMotionEvent("chair_ruling", motion, models.MotionStatusProposed)
+	return map[string]any{"motion": motion}, nil
+}
+
+// sendFrame queues a message for this client alone.
+func (c *Client) sendFrame(message models.WSMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling %s frame: %v", message.Action, err)
 		return
 	}
 
-	roomID, ok := payload["roomId"].(string)
-	if !ok {
-		log.Printf("Invalid room ID")
-		return
+	if !c.enqueue(data) {
+		log.Printf("Dropping %s frame for client %s", message.Action, c.userID.Hex())
 	}
+}
 
-	broadcastMsg := models.WSMessage{
-		Action: "vote_cast",
-		Type:   models.TypeMotion,
-		Payload: map[string]any{
-			"motionId": motionIDStr,
-			"voterID":  c.userID,
-			"vote":     voteResult,
-		},
+// enqueue hands data to the write pump without blocking. It reports false
+// if the client's buffer is full or the hub has already closed it.
+func (c *Client) enqueue(data []byte) bool {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	if c.closed {
+		return false
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		return false
 	}
+}
 
-	c.hub.BroadcastToRoom(roomID, broadcastMsg)
+func (c *Client) closeSend() {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
 }
 
 func UpgradeConnection(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
@@ -1,11 +1,14 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -16,6 +19,26 @@ type Hub struct {
 	Unregister chan *Client
 	broadcast  chan []byte
 	mutex      sync.RWMutex
+
+	// history holds each room's recent events for clients that reconnect.
+	history map[string]*roomHistory
+
+	// broker fans room events out to the hubs on every instance, which
+	// tell each other apart by id.
+	broker Broker
+	id     string
+	outbox *outbox
+
+	// presence and typing are kept for every instance's clients, as
+	// reported through the broker; hubsSeen notes when each hub was last
+	// heard from.
+	presence      map[string]map[primitive.ObjectID]*presenceState
+	typing        map[string]map[primitive.ObjectID]string
+	hubsSeen      map[string]time.Time
+	presenceMutex sync.Mutex
+
+	typingTimers map[typingKey]*time.Timer
+	typingMutex  sync.Mutex
 }
 
 type Client struct {
@@ -24,64 +47,119 @@ type Client struct {
 	send   chan []byte
 	userID primitive.ObjectID
 	rooms  map[string]bool
+
+	// sendMutex guards closed, so nothing is queued on send after the hub
+	// has closed it and it is only ever closed once.
+	sendMutex sync.Mutex
+	closed    bool
+
+	// protocol is the WebSocket protocol version agreed at connect time.
+	protocol int
+
+	// hidden is set for users who do not share their online status.
+	hidden bool
 }
 
-func NewHub() *Hub {
-	return &Hub{
+// NewHub creates a hub and subscribes it to the broker, which it shares with
+// the hubs on every other instance.
+func NewHub(broker Broker) (*Hub, error) {
+	h := &Hub{
 		clients:    make(map[*Client]bool),
 		rooms:      make(map[string]map[*Client]bool),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
 		broadcast:  make(chan []byte, 256),
+		history:    make(map[string]*roomHistory),
+		broker:     broker,
+		id:         primitive.NewObjectID().Hex(),
+		outbox:     newOutbox(),
+
+		presence:     make(map[string]map[primitive.ObjectID]*presenceState),
+		typing:       make(map[string]map[primitive.ObjectID]string),
+		hubsSeen:     make(map[string]time.Time),
+		typingTimers: make(map[typingKey]*time.Timer),
+	}
+	if err := broker.Subscribe(context.Background(), h.receive); err != nil {
+		return nil, err
 	}
+	go h.drainOutbox()
+	return h, nil
 }
 
 func (h *Hub) Run() {
+	sweep := time.NewTicker(historySweep)
+	defer sweep.Stop()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
 	for {
 		select {
 		case client := <-h.Register:
 			h.mutex.Lock()
 			h.clients[client] = true
+			h.reportPresence("", client)
 			h.mutex.Unlock()
 			log.Printf("Client connected: %s", client.userID.Hex())
 
 		case client := <-h.Unregister:
 			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-
-				for roomID := range client.rooms {
-					h.removeFromRoom(client, roomID)
-				}
-			}
+			h.dropClient(client)
 			h.mutex.Unlock()
 			log.Printf("Client disconnected: %s", client.userID.Hex())
 
 		case message := <-h.broadcast:
-			h.mutex.RLock()
+			h.mutex.Lock()
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+				if !client.enqueue(message) {
+					h.dropClient(client)
 				}
 			}
-			h.mutex.RUnlock()
+			h.mutex.Unlock()
+
+		case <-sweep.C:
+			h.sweepHistory()
+
+		case <-heartbeat.C:
+			h.outbox.push(roomEvent{Kind: roomEventHeartbeat})
+			h.sweepHubs()
 		}
 	}
 }
 
-func (h *Hub) JoinRoom(client *Client, roomID string) {
+// dropClient disconnects a client that has gone away or fallen too far
+// behind to keep up; the latter can reconnect and resume. It is called with
+// the hub mutex held and is safe to call more than once.
+func (h *Hub) dropClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	for roomID := range client.rooms {
+		h.removeFromRoom(client, roomID)
+	}
+	h.reportPresence("", client)
+	client.closeSend()
+}
+
+// JoinRoom adds the client to a room and returns the room's current cursor,
+// from which the client's view of the room is complete.
+func (h *Hub) JoinRoom(client *Client, roomID string) models.RoomCursor {
+	h.seedHistory(roomID)
+
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
+	h.joinRoom(client, roomID)
+	return h.cursor(roomID)
+}
+
+func (h *Hub) joinRoom(client *Client, roomID string) {
 	if h.rooms[roomID] == nil {
 		h.rooms[roomID] = make(map[*Client]bool)
 	}
 	h.rooms[roomID][client] = true
 	client.rooms[roomID] = true
+	h.reportPresence(roomID, client)
 
 	log.Printf("Client %s joined room %s", client.userID.Hex(), roomID)
 }
@@ -97,36 +175,179 @@ func (h *Hub) removeFromRoom(client *Client, roomID string) {
 		delete(room, client)
 		if len(room) == 0 {
 			delete(h.rooms, roomID)
+			h.touchHistory(roomID)
 		}
+		if h.localConnections(roomID, client.userID) == 0 {
+			h.stopTyping(typingKey{roomID: roomID, userID: client.userID})
+		}
+		h.reportPresence(roomID, client)
 	}
 	delete(client.rooms, roomID)
 }
 
+// roomEvent is what hubs exchange through the broker: a broadcast, a user
+// losing access to a room, a room being closed, a change in a user's connections or typing, or a
+// hub's heartbeat.
+type roomEvent struct {
+	Kind    string              `json:"kind"`
+	Hub     string              `json:"hub,omitempty"`
+	RoomID  string              `json:"roomId"`
+	Action  string              `json:"action,omitempty"`
+	Type    models.MessageType  `json:"type,omitempty"`
+	Payload json.RawMessage     `json:"payload,omitempty"`
+	UserID  *primitive.ObjectID `json:"userId,omitempty"`
+
+	Connections int                  `json:"connections,omitempty"`
+	Hidden      bool                 `json:"hidden,omitempty"`
+	Friends     []primitive.ObjectID `json:"friends,omitempty"`
+	Typing      bool                 `json:"typing,omitempty"`
+}
+
+const (
+	roomEventBroadcast = "broadcast"
+	roomEventRevoke    = "revoke"
+	roomEventClose     = "close"
+	roomEventPresence  = "presence"
+	roomEventTyping    = "typing"
+	roomEventHeartbeat = "heartbeat"
+)
+
+// BroadcastToRoom sends a message to everyone in the room on every instance.
+// If the broker is unreachable the message still reaches this instance's
+// clients, though without a number it cannot be replayed.
 func (h *Hub) BroadcastToRoom(roomID string, message models.WSMessage) {
-	h.mutex.RLock()
-	room, exists := h.rooms[roomID]
-	h.mutex.RUnlock()
+	payload, err := json.Marshal(message.Payload)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
 
-	if !exists {
+	err = h.publishToRoom(roomEvent{
+		Kind:    roomEventBroadcast,
+		RoomID:  roomID,
+		Action:  message.Action,
+		Type:    message.Type,
+		Payload: payload,
+	})
+	if err != nil {
+		log.Printf("Error publishing to room %s, delivering locally: %v", roomID, err)
+		h.deliverEphemeral(roomID, message)
+	}
+}
+
+func (h *Hub) publish(event roomEvent) error {
+	event.Hub = h.id
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return h.broker.Publish(ctx, data)
+}
+
+// publishToRoom publishes an event for the broker to number in its room's
+// stream.
+func (h *Hub) publishToRoom(event roomEvent) error {
+	event.Hub = h.id
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return h.broker.PublishToRoom(ctx, event.RoomID, data)
+}
+
+func (h *Hub) receive(data []byte, stamp *Stamp) {
+	var event roomEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		log.Printf("Error decoding room event: %v", err)
+		return
+	}
+	h.handleEvent(event, stamp)
+}
+
+func (h *Hub) handleEvent(event roomEvent, stamp *Stamp) {
+	h.sawHub(event.Hub)
+
+	switch event.Kind {
+	case roomEventBroadcast:
+		message := models.WSMessage{
+			Action:  event.Action,
+			Type:    event.Type,
+			Payload: event.Payload,
+		}
+		if stamp == nil {
+			h.deliverEphemeral(event.RoomID, message)
+			break
+		}
+		h.deliver(event.RoomID, message, *stamp)
+	case roomEventRevoke:
+		if event.UserID != nil {
+			h.revoke(*event.UserID, event.RoomID)
+		}
+	case roomEventClose:
+		h.closeRoom(event.RoomID)
+	case roomEventPresence:
+		if event.UserID != nil {
+			h.applyPresence(event)
+		}
+	case roomEventTyping:
+		if event.UserID != nil {
+			h.applyTyping(event)
+		}
+	case roomEventHeartbeat:
+	default:
+		log.Printf("Unknown room event kind: %s", event.Kind)
+	}
+}
+
+// deliver sends the message with the number the broker gave it to this
+// instance's clients in the room and keeps it for replay. Clients whose
+// buffers are full are dropped rather than allowed to stall the room.
+func (h *Hub) deliver(roomID string, message models.WSMessage, stamp Stamp) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	message.RoomID = roomID
+	message.Seq = stamp.Seq
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
 		return
 	}
+	h.historyOf(roomID).record(stamp, data)
+	h.sendToRoom(roomID, data)
+}
 
+// deliverEphemeral sends a message that is only worth seeing live, such as a
+// typing indicator or a change in presence, without numbering it or keeping
+// it for replay.
+func (h *Hub) deliverEphemeral(roomID string, message models.WSMessage) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	message.RoomID = roomID
 	data, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("Error marshaling message: %v", err)
 		return
 	}
+	h.sendToRoom(roomID, data)
+}
 
-	h.mutex.RLock()
-	for client := range room {
-		select {
-		case client.send <- data:
-		default:
-			close(client.send)
-			delete(h.clients, client)
+// sendToRoom is called with the hub mutex held.
+func (h *Hub) sendToRoom(roomID string, data []byte) {
+	for client := range h.rooms[roomID] {
+		if !client.enqueue(data) {
+			log.Printf("Dropping slow client %s", client.userID.Hex())
+			h.dropClient(client)
 		}
 	}
-	h.mutex.RUnlock()
 }
 
 func (h *Hub) GetClientsInRoom(roomID string) []*Client {
@@ -144,3 +365,136 @@ func (h *Hub) GetClientsInRoom(roomID string) []*Client {
 	}
 	return clients
 }
+
+func (h *Hub) BroadcastMotionEvent(action string, motion *models.Motion, previousStatus models.MotionStatus) {
+	payload := map[string]any{
+		"motion": motion,
+	}
+	if previousStatus != "" {
+		payload["previousStatus"] = previousStatus
+	}
+
+	h.BroadcastToRoom(models.CreateCommitteeRoomID(motion.CommitteeID), models.WSMessage{
+		Action:  action,
+		Type:    models.TypeMotion,
+		Payload: payload,
+	})
+}
+
+func (h *Hub) BroadcastVote(motion *models.Motion, vote *models.Vote, tally models.VoteTally) {
+	h.BroadcastToRoom(models.CreateCommitteeRoomID(motion.CommitteeID), models.WSMessage{
+		Action: "vote_cast",
+		Type:   models.TypeMotion,
+		Payload: map[string]any{
+			"motionId": motion.ID,
+			"voterID":  vote.UserID,
+			"vote":     vote.Result,
+			"tally":    tally,
+		},
+	})
+}
+
+// BroadcastVotingClosed announces the result and whatever else the motion
+// changed when it passed.
+func (h *Hub) BroadcastVotingClosed(motion *models.Motion, effects services.AdoptionEffects) {
+	h.BroadcastMotionEvent("voting_closed", motion, models.MotionStatusOpen)
+
+	if meeting := effects.Meeting; meeting != nil {
+		action := "meeting_recessed"
+		if meeting.Status == models.MeetingStatusAdjourned {
+			action = "meeting_adjourned"
+		}
+		h.BroadcastMeetingEvent(action, meeting)
+		h.RefreshQuorum(meeting.CommitteeID)
+	}
+
+	if effects.Settings != nil {
+		h.BroadcastSettings(effects.Settings)
+		h.RefreshQuorum(effects.Settings.CommitteeID)
+	}
+
+	if effects.Minutes != nil {
+		h.BroadcastMinutes("minutes_approved", effects.Minutes)
+	}
+
+	affected := effects.Motion
+	if affected == nil {
+		return
+	}
+
+	switch motion.Kind {
+	case models.MotionKindReconsider, models.MotionKindRescind:
+		h.BroadcastMotionEvent("motion_overturned", affected, "")
+	case models.MotionKindAppeal:
+		h.BroadcastMotionEvent("ruling_overturned", affected, "")
+	case models.MotionKindAmend:
+		h.BroadcastMotionEvent("motion_amended", affected, "")
+	case models.MotionKindCallQuestion:
+		h.BroadcastMotionEvent("debate_closed", affected, "")
+	case models.MotionKindSuspendRules:
+		if motion.SuspendedRule == models.SuspendDebate {
+			h.BroadcastMotionEvent("debate_closed", affected, "")
+		} else {
+			h.BroadcastMotionEvent("motion_status_changed", affected, models.MotionStatusProposed)
+		}
+	default:
+		h.BroadcastMotionEvent("motion_status_changed", affected, models.MotionStatusOpen)
+	}
+}
+
+func (h *Hub) BroadcastMeetingEvent(action string, meeting *models.Meeting) {
+	h.BroadcastToRoom(models.CreateCommitteeRoomID(meeting.CommitteeID), models.WSMessage{
+		Action: action,
+		Type:   models.TypeSystem,
+		Payload: map[string]any{
+			"meeting": meeting,
+		},
+	})
+}
+
+func (h *Hub) BroadcastSettings(settings *models.CommitteeSettings) {
+	h.BroadcastToRoom(models.CreateCommitteeRoomID(settings.CommitteeID), models.WSMessage{
+		Action: "settings_updated",
+		Type:   models.TypeSystem,
+		Payload: map[string]any{
+			"settings": settings,
+		},
+	})
+}
+
+func (h *Hub) BroadcastMinutes(action string, minutes *models.MinutesDraft) {
+	h.BroadcastToRoom(models.CreateCommitteeRoomID(minutes.CommitteeID), models.WSMessage{
+		Action: action,
+		Type:   models.TypeSystem,
+		Payload: map[string]any{
+			"minutesId": minutes.ID,
+			"version":   minutes.Version,
+			"status":    minutes.Status,
+		},
+	})
+}
+
+func (h *Hub) BroadcastComment(action string, motion *models.Motion, comment *models.Comment, counts models.StanceCounts) {
+	h.BroadcastToRoom(models.CreateCommitteeRoomID(motion.CommitteeID), models.WSMessage{
+		Action: action,
+		Type:   models.TypeMotion,
+		Payload: map[string]any{
+			"motionId":     motion.ID,
+			"comment":      comment,
+			"stanceCounts": counts,
+		},
+	})
+}
+
+// Secret ballots only announce how many have been cast; a running tally next
+// to each arrival would reveal individual choices.
+func (h *Hub) BroadcastSecretBallot(motion *models.Motion, ballotsCast int64) {
+	h.BroadcastToRoom(models.CreateCommitteeRoomID(motion.CommitteeID), models.WSMessage{
+		Action: "ballot_cast",
+		Type:   models.TypeMotion,
+		Payload: map[string]any{
+			"motionId":    motion.ID,
+			"ballotsCast": ballotsCast,
+		},
+	})
+}
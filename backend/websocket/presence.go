@@ -0,0 +1,455 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// typingTTL is how long a user counts as typing after their last typing
+	// frame. Frames inside the window only extend it, so the room hears
+	// once when typing starts and once when it stops.
+	typingTTL = 5 * time.Second
+
+	// A hub that misses three heartbeats is presumed gone, along with every
+	// connection it reported.
+	heartbeatInterval = 30 * time.Second
+	hubTimeout        = 3 * heartbeatInterval
+)
+
+// presenceState counts one user's connections on each hub, either in a room
+// or, under the empty room ID, to the server at all.
+type presenceState struct {
+	hidden      bool
+	connections map[string]int
+	friends     []primitive.ObjectID
+}
+
+func (s *presenceState) total() int {
+	total := 0
+	for _, count := range s.connections {
+		total += count
+	}
+	return total
+}
+
+type typingKey struct {
+	roomID string
+	userID primitive.ObjectID
+}
+
+// outbox queues the presence and typing events a hub publishes. Events are
+// queued while the hub mutex is held and published in order from a single
+// goroutine, so the connection counts other hubs see are never stale.
+type outbox struct {
+	mutex  sync.Mutex
+	events []roomEvent
+	wake   chan struct{}
+}
+
+func newOutbox() *outbox {
+	return &outbox{wake: make(chan struct{}, 1)}
+}
+
+func (o *outbox) push(event roomEvent) {
+	o.mutex.Lock()
+	o.events = append(o.events, event)
+	o.mutex.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *outbox) take() []roomEvent {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	events := o.events
+	o.events = nil
+	return events
+}
+
+func (h *Hub) drainOutbox() {
+	for range h.outbox.wake {
+		for _, event := range h.outbox.take() {
+			event.Hub = h.id
+			if event.Kind == roomEventPresence && event.RoomID == "" && !event.Hidden {
+				event.Friends = friendIDs(*event.UserID)
+			}
+			if err := h.publish(event); err != nil {
+				log.Printf("Error publishing %s event, applying locally: %v", event.Kind, err)
+				h.handleEvent(event, nil)
+			}
+		}
+	}
+}
+
+// reportPresence queues the client's user's current number of connections
+// on this hub, in a room or overall. It is called with the hub mutex held.
+func (h *Hub) reportPresence(roomID string, client *Client) {
+	userID := client.userID
+	h.outbox.push(roomEvent{
+		Kind:        roomEventPresence,
+		RoomID:      roomID,
+		UserID:      &userID,
+		Connections: h.localConnections(roomID, userID),
+		Hidden:      client.hidden,
+	})
+}
+
+// localConnections counts the user's connections on this hub, in a room or
+// overall. It is called with the hub mutex held.
+func (h *Hub) localConnections(roomID string, userID primitive.ObjectID) int {
+	clients := h.clients
+	if roomID != "" {
+		clients = h.rooms[roomID]
+	}
+
+	count := 0
+	for client := range clients {
+		if client.userID == userID {
+			count++
+		}
+	}
+	return count
+}
+
+// applyPresence records what a hub reported and, when a visible user comes
+// or goes, tells the room or, for the server as a whole, their friends.
+func (h *Hub) applyPresence(event roomEvent) {
+	userID := *event.UserID
+	changed, online, friends := h.setConnections(event, userID)
+
+	if changed && !event.Hidden {
+		h.announcePresence(event.RoomID, userID, online, friends)
+	}
+
+	if event.Hub == h.id {
+		if committeeID, ok := models.ParseCommitteeRoomID(event.RoomID); ok {
+			go h.RefreshQuorum(committeeID)
+		}
+	}
+}
+
+// setConnections reports whether the user went online or offline in the room
+// as a result, and which friends to tell if it was the server as a whole.
+func (h *Hub) setConnections(event roomEvent, userID primitive.ObjectID) (changed, online bool, friends []primitive.ObjectID) {
+	roomID, hub, count := event.RoomID, event.Hub, event.Connections
+
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+
+	users, ok := h.presence[roomID]
+	if !ok {
+		users = make(map[primitive.ObjectID]*presenceState)
+		h.presence[roomID] = users
+	}
+	state, ok := users[userID]
+	if !ok {
+		state = &presenceState{connections: make(map[string]int)}
+		users[userID] = state
+	}
+
+	wasOnline := state.total() > 0
+	state.hidden = event.Hidden
+	if event.Friends != nil {
+		state.friends = event.Friends
+	}
+	if count > 0 {
+		state.connections[hub] = count
+	} else {
+		delete(state.connections, hub)
+	}
+	online = state.total() > 0
+
+	if !online {
+		delete(users, userID)
+		if len(users) == 0 {
+			delete(h.presence, roomID)
+		}
+	}
+	return wasOnline != online, online, state.friends
+}
+
+// announcePresence tells a room, or the user's friends, that the user came
+// or went. Each instance works this out from its own view of connections, so
+// room announcements go out live rather than numbered; a resuming client is
+// sent the room's presence instead.
+func (h *Hub) announcePresence(roomID string, userID primitive.ObjectID, online bool, friends []primitive.ObjectID) {
+	if roomID != "" {
+		action := "left_room"
+		if online {
+			action = "joined_room"
+		}
+		h.deliverEphemeral(roomID, models.WSMessage{
+			Action:  action,
+			Type:    models.TypeSystem,
+			Payload: map[string]any{"userId": userID},
+		})
+		return
+	}
+
+	action := "user_offline"
+	if online {
+		action = "user_online"
+	}
+	notify := make(map[primitive.ObjectID]bool, len(friends))
+	for _, friendID := range friends {
+		notify[friendID] = true
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for client := range h.clients {
+		if notify[client.userID] {
+			client.sendFrame(models.WSMessage{
+				Action:  action,
+				Type:    models.TypeSystem,
+				Payload: map[string]any{"userId": userID},
+			})
+		}
+	}
+}
+
+// RoomPresence lists who is in a room on any instance, leaving out users who
+// hide their online status.
+func (h *Hub) RoomPresence(roomID string) []models.RoomPresence {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+
+	presence := []models.RoomPresence{}
+	for userID, state := range h.presence[roomID] {
+		if state.hidden {
+			continue
+		}
+		_, typing := h.typing[roomID][userID]
+		presence = append(presence, models.RoomPresence{
+			UserID:      userID,
+			Connections: state.total(),
+			Typing:      typing,
+		})
+	}
+	return presence
+}
+
+// startTyping marks the client's user as typing in a room, or extends the
+// window if they already are.
+func (h *Hub) startTyping(client *Client, roomID string) {
+	if client.hidden {
+		return
+	}
+	key := typingKey{roomID: roomID, userID: client.userID}
+
+	h.typingMutex.Lock()
+	defer h.typingMutex.Unlock()
+
+	if timer, ok := h.typingTimers[key]; ok && timer.Stop() {
+		timer.Reset(typingTTL)
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(typingTTL, func() {
+		h.typingMutex.Lock()
+		defer h.typingMutex.Unlock()
+
+		if h.typingTimers[key] == timer {
+			delete(h.typingTimers, key)
+			h.pushTyping(key, false)
+		}
+	})
+	h.typingTimers[key] = timer
+	h.pushTyping(key, true)
+}
+
+// stopTyping ends a user's typing in a room before the window runs out,
+// when they send a message, say they stopped or leave.
+func (h *Hub) stopTyping(key typingKey) {
+	h.typingMutex.Lock()
+	defer h.typingMutex.Unlock()
+
+	timer, ok := h.typingTimers[key]
+	if !ok {
+		return
+	}
+	timer.Stop()
+	delete(h.typingTimers, key)
+	h.pushTyping(key, false)
+}
+
+func (h *Hub) pushTyping(key typingKey, typing bool) {
+	userID := key.userID
+	h.outbox.push(roomEvent{
+		Kind:   roomEventTyping,
+		RoomID: key.roomID,
+		UserID: &userID,
+		Typing: typing,
+	})
+}
+
+func (h *Hub) applyTyping(event roomEvent) {
+	userID := *event.UserID
+
+	h.presenceMutex.Lock()
+	_, wasTyping := h.typing[event.RoomID][userID]
+	if event.Typing {
+		if h.typing[event.RoomID] == nil {
+			h.typing[event.RoomID] = make(map[primitive.ObjectID]string)
+		}
+		h.typing[event.RoomID][userID] = event.Hub
+	} else if wasTyping {
+		delete(h.typing[event.RoomID], userID)
+		if len(h.typing[event.RoomID]) == 0 {
+			delete(h.typing, event.RoomID)
+		}
+	}
+	h.presenceMutex.Unlock()
+
+	if wasTyping == event.Typing {
+		return
+	}
+	h.announceTyping(event.RoomID, userID, event.Typing)
+}
+
+func (h *Hub) announceTyping(roomID string, userID primitive.ObjectID, typing bool) {
+	action := "typing_stopped"
+	if typing {
+		action = "typing"
+	}
+	h.deliverEphemeral(roomID, models.WSMessage{
+		Action:  action,
+		Type:    models.TypeSystem,
+		Payload: map[string]any{"userId": userID},
+	})
+}
+
+func (h *Hub) sawHub(hub string) {
+	if hub == "" {
+		return
+	}
+	h.presenceMutex.Lock()
+	h.hubsSeen[hub] = time.Now()
+	h.presenceMutex.Unlock()
+}
+
+// sweepHubs forgets the connections and typing of hubs that have stopped
+// sending heartbeats, as if each of their clients had disconnected.
+func (h *Hub) sweepHubs() {
+	type departure struct {
+		roomID  string
+		userID  primitive.ObjectID
+		typing  bool
+		hidden  bool
+		friends []primitive.ObjectID
+	}
+	var gone []departure
+
+	h.presenceMutex.Lock()
+	cutoff := time.Now().Add(-hubTimeout)
+	for hub, seen := range h.hubsSeen {
+		if hub == h.id || seen.After(cutoff) {
+			continue
+		}
+		delete(h.hubsSeen, hub)
+
+		for roomID, users := range h.presence {
+			for userID, state := range users {
+				if _, ok := state.connections[hub]; !ok {
+					continue
+				}
+				delete(state.connections, hub)
+				if state.total() == 0 {
+					delete(users, userID)
+					gone = append(gone, departure{roomID: roomID, userID: userID, hidden: state.hidden, friends: state.friends})
+				}
+			}
+			if len(users) == 0 {
+				delete(h.presence, roomID)
+			}
+		}
+		for roomID, users := range h.typing {
+			for userID, owner := range users {
+				if owner == hub {
+					delete(users, userID)
+					gone = append(gone, departure{roomID: roomID, userID: userID, typing: true})
+				}
+			}
+			if len(users) == 0 {
+				delete(h.typing, roomID)
+			}
+		}
+	}
+	h.presenceMutex.Unlock()
+
+	for _, d := range gone {
+		switch {
+		case d.typing:
+			h.announceTyping(d.roomID, d.userID, false)
+		case !d.hidden:
+			h.announcePresence(d.roomID, d.userID, false, d.friends)
+		}
+	}
+}
+
+// showsOnlineStatus reads the user's privacy setting when they connect. If
+// it cannot be read the user is treated as hidden.
+func showsOnlineStatus(userID primitive.ObjectID) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := config.GetCollection("users").FindOne(ctx, bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"settings": 1})).Decode(&user)
+	if err != nil {
+		log.Printf("Error loading privacy settings for %s: %v", userID.Hex(), err)
+		return false
+	}
+
+	return user.Settings.Privacy.OnlineStatusShown()
+}
+
+// friendIDs lists the users told when this user comes online or goes
+// offline.
+func friendIDs(userID primitive.ObjectID) []primitive.ObjectID {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.GetCollection("friendships").Find(ctx, bson.M{
+		"status": models.FriendStatusAccepted,
+		"$or": []bson.M{
+			{"requesterId": userID},
+			{"addresseeId": userID},
+		},
+	})
+	if err != nil {
+		log.Printf("Error loading friends of %s: %v", userID.Hex(), err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var friendships []models.Friendship
+	if err := cursor.All(ctx, &friendships); err != nil {
+		log.Printf("Error decoding friends of %s: %v", userID.Hex(), err)
+		return nil
+	}
+
+	friends := make([]primitive.ObjectID, 0, len(friendships))
+	for _, friendship := range friendships {
+		if friendship.RequesterID == userID {
+			friends = append(friends, friendship.AddresseeID)
+		} else {
+			friends = append(friends, friendship.RequesterID)
+		}
+	}
+	return friends
+}
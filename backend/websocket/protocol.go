@@ -0,0 +1,374 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// subprotocols lists the Sec-WebSocket-Protocol names the server accepts,
+// newest first, since the upgrader picks the first one the client offers.
+var subprotocols = []string{"ceros.v2", "ceros.v1"}
+
+var protocolVersions = map[string]int{
+	"ceros.v1": models.ProtocolV1,
+	"ceros.v2": models.ProtocolV2,
+}
+
+// negotiatedVersion maps the subprotocol agreed during the upgrade to a
+// protocol version. Clients that offered none get version 1.
+func negotiatedVersion(subprotocol string) int {
+	if version, ok := protocolVersions[subprotocol]; ok {
+		return version
+	}
+	return models.ProtocolV1
+}
+
+// protocolError is a request the server could not act on because of the
+// frame itself rather than the state of the committee.
+type protocolError struct {
+	code    models.WSErrorCode
+	message string
+}
+
+func (e *protocolError) Error() string {
+	return e.message
+}
+
+func invalidPayload(format string, args ...any) error {
+	return &protocolError{code: models.WSErrorValidation, message: fmt.Sprintf(format, args...)}
+}
+
+var errRequestIDRequired = invalidPayload("a requestId is required")
+
+var serviceErrorCodes = map[services.ErrorClass]models.WSErrorCode{
+	services.ErrorNotFound:   models.WSErrorNotFound,
+	services.ErrorPermission: models.WSErrorPermission,
+	services.ErrorValidation: models.WSErrorValidation,
+	services.ErrorConflict:   models.WSErrorConflict,
+}
+
+// ErrorCode classifies an error from the WebSocket package, including room
+// authorization, the same way it is reported in error frames.
+func ErrorCode(err error) models.WSErrorCode {
+	var protoErr *protocolError
+	switch {
+	case errors.As(err, &protoErr):
+		return protoErr.code
+	case errors.Is(err, errNotParticipant), errors.Is(err, errReceiveOnly), errors.Is(err, errNotCommitteeRoom):
+		return models.WSErrorPermission
+	case errors.Is(err, errUnknownRoom):
+		return models.WSErrorNotFound
+	case errors.Is(err, errInvalidRoom), errors.Is(err, errParentRoom), errors.Is(err, errNotInRoom):
+		return models.WSErrorValidation
+	}
+	if code, ok := serviceErrorCodes[services.ClassifyError(err)]; ok {
+		return code
+	}
+	return models.WSErrorInternal
+}
+
+type actionHandler func(c *Client, req models.WSRequest) (any, error)
+
+var actionHandlers map[string]actionHandler
+
+func init() {
+	actionHandlers = map[string]actionHandler{
+		"join_room":            (*Client).handleJoinRoom,
+		"leave_room":           (*Client).handleLeaveRoom,
+		"resume":               (*Client).handleResume,
+		"send_message":         (*Client).handleSendMessage,
+		"reply_to_message":     (*Client).handleReplyToMessage,
+		"typing":               (*Client).handleTyping,
+		"propose_motion":       (*Client).handleProposeMotion,
+		"second_motion":        (*Client).handleSecondMotion,
+		"change_motion_status": (*Client).handleChangeMotionStatus,
+		"vote_motion":          (*Client).handleVoteMotion,
+		"close_voting":         (*Client).handleCloseVoting,
+		"rule_on_motion":       (*Client).handleRuleOnMotion,
+		"raise_hand":           (*Client).handleRaiseHand,
+		"lower_hand":           (*Client).handleLowerHand,
+		"recognize_speaker":    (*Client).handleRecognizeSpeaker,
+		"yield_floor":          (*Client).handleYieldFloor,
+	}
+}
+
+// handleRequest runs one inbound frame and answers it. Version 2 clients must
+// tag every frame with a requestId and always get an ack or an error back;
+// version 1 clients get acks only for frames they tagged.
+func (c *Client) handleRequest(req models.WSRequest) {
+	if c.protocol >= models.ProtocolV2 && req.RequestID == "" {
+		c.reject(req, errRequestIDRequired)
+		return
+	}
+
+	handle, ok := actionHandlers[req.Action]
+	if !ok {
+		c.reject(req, &protocolError{
+			code:    models.WSErrorUnknownAction,
+			message: fmt.Sprintf("unknown action %q", req.Action),
+		})
+		return
+	}
+
+	result, err := handle(c, req)
+	if err != nil {
+		c.reject(req, err)
+		return
+	}
+	c.ack(req, result)
+}
+
+func (c *Client) ack(req models.WSRequest, result any) {
+	if req.RequestID == "" {
+		return
+	}
+	c.sendFrame(models.WSMessage{
+		Action:    "ack",
+		Type:      models.TypeSystem,
+		RequestID: req.RequestID,
+		Payload:   models.WSAck{Action: req.Action, Result: result},
+	})
+}
+
+func (c *Client) reject(req models.WSRequest, err error) {
+	code := ErrorCode(err)
+	message := err.Error()
+	if code == models.WSErrorInternal {
+		log.Printf("Error handling %s for client %s: %v", req.Action, c.userID.Hex(), err)
+		message = "internal error"
+	}
+
+	c.sendFrame(models.WSMessage{
+		Action:    "error",
+		Type:      models.TypeSystem,
+		RequestID: req.RequestID,
+		Payload:   models.WSError{Action: req.Action, Code: code, Error: message},
+	})
+}
+
+func (c *Client) sendWelcome() {
+	supported := make([]int, 0, len(subprotocols))
+	for i := len(subprotocols) - 1; i >= 0; i-- {
+		supported = append(supported, protocolVersions[subprotocols[i]])
+	}
+	c.sendFrame(models.WSMessage{
+		Action:  "welcome",
+		Type:    models.TypeSystem,
+		Payload: models.WSWelcome{Protocol: c.protocol, Supported: supported},
+	})
+}
+
+// payload is the decoded body of one action.
+type payload interface {
+	validate() error
+}
+
+// floorPayload is the payload of an action on a motion's speaking queue.
+type floorPayload interface {
+	payload
+	motionID() primitive.ObjectID
+}
+
+func decodePayload(req models.WSRequest, p payload) error {
+	if len(req.Payload) == 0 || string(req.Payload) == "null" {
+		return invalidPayload("%s needs a payload", req.Action)
+	}
+	if err := json.Unmarshal(req.Payload, p); err != nil {
+		return invalidPayload("invalid %s payload: %v", req.Action, err)
+	}
+	return p.validate()
+}
+
+// roomPayload also accepts the bare room ID string version 1 clients send.
+type roomPayload struct {
+	RoomID string `json:"roomId"`
+}
+
+func (p *roomPayload) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &p.RoomID)
+	}
+	type plain roomPayload
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+func (p *roomPayload) validate() error {
+	if p.RoomID == "" {
+		return errInvalidRoom
+	}
+	return nil
+}
+
+// resumePayload lists the last event a reconnecting client saw in each room.
+type resumePayload struct {
+	Rooms []models.RoomCursor `json:"rooms"`
+}
+
+func (p *resumePayload) validate() error {
+	if len(p.Rooms) == 0 {
+		return invalidPayload("at least one room is required")
+	}
+	if len(p.Rooms) > maxResumeRooms {
+		return invalidPayload("at most %d rooms can be resumed at once", maxResumeRooms)
+	}
+	for _, room := range p.Rooms {
+		if room.RoomID == "" {
+			return errInvalidRoom
+		}
+	}
+	return nil
+}
+
+// typingPayload turns the indicator off when typing is false; leaving it out
+// means the sender is typing.
+type typingPayload struct {
+	RoomID string `json:"roomId"`
+	Typing *bool  `json:"typing"`
+}
+
+func (p *typingPayload) validate() error {
+	if p.RoomID == "" {
+		return errInvalidRoom
+	}
+	return nil
+}
+
+type messagePayload struct {
+	RoomID  string `json:"roomId"`
+	Content string `json:"content"`
+}
+
+func (p *messagePayload) validate() error {
+	if p.RoomID == "" {
+		return errInvalidRoom
+	}
+	if strings.TrimSpace(p.Content) == "" {
+		return invalidPayload("message content is required")
+	}
+	return nil
+}
+
+type replyPayload struct {
+	messagePayload
+	ParentMessageID primitive.ObjectID `json:"parentMessageId"`
+}
+
+func (p *replyPayload) validate() error {
+	if err := p.messagePayload.validate(); err != nil {
+		return err
+	}
+	if p.ParentMessageID.IsZero() {
+		return invalidPayload("parentMessageId is required")
+	}
+	return nil
+}
+
+type proposeMotionPayload struct {
+	CommitteeID        primitive.ObjectID    `json:"committeeId"`
+	Title              string                `json:"title"`
+	Description        string                `json:"description"`
+	IsSpecial          bool                  `json:"isSpecial"`
+	Threshold          models.VoteThreshold  `json:"threshold"`
+	VoteMode           models.VoteMode       `json:"voteMode"`
+	Kind               models.MotionKind     `json:"kind"`
+	RefMotionID        *primitive.ObjectID   `json:"refMotionId"`
+	ParentMotionID     *primitive.ObjectID   `json:"parentMotionId"`
+	ReferToCommitteeID *primitive.ObjectID   `json:"referToCommitteeId"`
+	MinutesID          *primitive.ObjectID   `json:"minutesId"`
+	SuspendedRule      models.SuspendedRule  `json:"suspendedRule"`
+	Amendment          *models.AmendmentText `json:"amendment"`
+	PostponeUntil      *time.Time            `json:"postponeUntil"`
+}
+
+func (p *proposeMotionPayload) validate() error {
+	if p.CommitteeID.IsZero() {
+		return invalidPayload("committeeId is required")
+	}
+	return nil
+}
+
+func (p *proposeMotionPayload) input() services.ProposeMotionInput {
+	return services.ProposeMotionInput{
+		Title:       p.Title,
+		Description: p.Description,
+		IsSpecial:   p.IsSpecial,
+		Threshold:   p.Threshold,
+		VoteMode:    p.VoteMode,
+		Kind:        p.Kind,
+		RefMotionID: optionalID(p.RefMotionID),
+		SubsidiaryInput: services.SubsidiaryInput{
+			ParentMotionID:     optionalID(p.ParentMotionID),
+			Amendment:          p.Amendment,
+			PostponeUntil:      p.PostponeUntil,
+			ReferToCommitteeID: optionalID(p.ReferToCommitteeID),
+		},
+		MinutesID:     optionalID(p.MinutesID),
+		SuspendedRule: p.SuspendedRule,
+	}
+}
+
+type motionPayload struct {
+	MotionID primitive.ObjectID `json:"motionId"`
+}
+
+func (p *motionPayload) motionID() primitive.ObjectID {
+	return p.MotionID
+}
+
+func (p *motionPayload) validate() error {
+	if p.MotionID.IsZero() {
+		return invalidPayload("motionId is required")
+	}
+	return nil
+}
+
+type motionStatusPayload struct {
+	motionPayload
+	Status models.MotionStatus `json:"status"`
+}
+
+func (p *motionStatusPayload) validate() error {
+	if err := p.motionPayload.validate(); err != nil {
+		return err
+	}
+	if p.Status == "" {
+		return invalidPayload("status is required")
+	}
+	return nil
+}
+
+type votePayload struct {
+	motionPayload
+	Vote models.VoteResult `json:"vote"`
+}
+
+type rulingPayload struct {
+	motionPayload
+	Decision    models.RulingDecision `json:"decision"`
+	Explanation string                `json:"explanation"`
+}
+
+// queuePayload names the member a queue action is about; without a userId it
+// is about the sender.
+type queuePayload struct {
+	motionPayload
+	Stance models.Stance       `json:"stance"`
+	UserID *primitive.ObjectID `json:"userId"`
+}
+
+// optionalID treats an empty string the same as a missing ID, as version 1
+// clients send both.
+func optionalID(id *primitive.ObjectID) *primitive.ObjectID {
+	if id == nil || id.IsZero() {
+		return nil
+	}
+	return id
+}
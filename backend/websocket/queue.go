@@ -0,0 +1,229 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errFloorChanged = errors.New("the floor changed hands before the time limit")
+
+// queueAttempts is how many times a queue change is applied to freshly
+// loaded state before giving up on a queue that keeps changing underneath it.
+const queueAttempts = 3
+
+// UpdateSpeakingQueue applies change to the motion's queue as stored, then
+// persists and broadcasts the result. Any instance may be changing the same
+// queue, so a save that loses the race reloads and applies change again.
+func (h *Hub) UpdateSpeakingQueue(ctx context.Context, motion *models.Motion, event string, change func(*models.SpeakingQueue) error) (*models.SpeakingQueue, error) {
+	settings, err := services.GetSettings(ctx, motion.CommitteeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var queue *models.SpeakingQueue
+	for attempt := 0; ; attempt++ {
+		queue, err = services.LoadSpeakingQueue(ctx, motion)
+		if err != nil {
+			return nil, err
+		}
+		services.ApplyQueueSettings(queue, settings)
+		if err := change(queue); err != nil {
+			return nil, err
+		}
+
+		err = services.SaveSpeakingQueue(ctx, queue)
+		if err == services.ErrQueueChanged && attempt+1 < queueAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	h.BroadcastToRoom(models.CreateCommitteeRoomID(motion.CommitteeID), models.WSMessage{
+		Action: "speaking_queue_updated",
+		Type:   models.TypeMotion,
+		Payload: map[string]any{
+			"motionId":      motion.ID,
+			"event":         event,
+			"queue":         queue,
+			"suggestedNext": services.SuggestNextSpeaker(queue),
+		},
+	})
+
+	return queue, nil
+}
+
+func (c *Client) handleRaiseHand(req models.WSRequest) (any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p queuePayload
+	motion, err := c.loadFloorMotion(ctx, req, &p, services.CapDebate)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.QueueEntry{
+		UserID:   c.userID,
+		Stance:   p.Stance,
+		RaisedAt: time.Now(),
+	}
+
+	queue, err := c.hub.UpdateSpeakingQueue(ctx, motion, "hand_raised", func(queue *models.SpeakingQueue) error {
+		return services.RaiseHand(queue, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"queue": queue}, nil
+}
+
+func (c *Client) handleLowerHand(req models.WSRequest) (any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p queuePayload
+	motion, err := c.loadFloorMotion(ctx, req, &p, services.CapDebate)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := c.queueTarget(p)
+	if userID != c.userID {
+		if err := c.authorizeCommittee(motion.CommitteeID, services.CapPreside); err != nil {
+			return nil, err
+		}
+	}
+
+	queue, err := c.hub.UpdateSpeakingQueue(ctx, motion, "hand_lowered", func(queue *models.SpeakingQueue) error {
+		return services.LowerHand(queue, models.QueueEntry{UserID: userID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"queue": queue}, nil
+}
+
+func (c *Client) handleRecognizeSpeaker(req models.WSRequest) (any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p queuePayload
+	motion, err := c.loadFloorMotion(ctx, req, &p, services.CapPreside)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := services.RequireQuorum(ctx, motion.CommitteeID); err != nil {
+		return nil, err
+	}
+
+	var entry *models.QueueEntry
+	if optionalID(p.UserID) != nil {
+		entry = &models.QueueEntry{UserID: *p.UserID}
+	}
+
+	queue, err := c.hub.UpdateSpeakingQueue(ctx, motion, "speaker_recognized", func(queue *models.SpeakingQueue) error {
+		return services.RecognizeSpeaker(queue, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if queue.FloorEndsAt != nil {
+		c.hub.expireFloor(motion.ID, queue.Speaker.UserID, *queue.FloorEndsAt)
+	}
+	return map[string]any{"queue": queue}, nil
+}
+
+// expireFloor takes the floor back from a speaker who runs past the time
+// limit, unless they have already yielded or someone else has been
+// recognized since. Both are judged from the queue as stored when the time
+// runs out, since another instance may have changed it.
+func (h *Hub) expireFloor(motionID, speakerID primitive.ObjectID, endsAt time.Time) {
+	time.AfterFunc(time.Until(endsAt), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		motion, err := services.GetMotion(ctx, motionID)
+		if err != nil {
+			log.Printf("Error expiring speaking time: %v", err)
+			return
+		}
+
+		_, err = h.UpdateSpeakingQueue(ctx, motion, "speaking_time_expired", func(queue *models.SpeakingQueue) error {
+			if queue.Speaker == nil || queue.Speaker.UserID != speakerID ||
+				queue.FloorEndsAt == nil || !queue.FloorEndsAt.Equal(endsAt) {
+				return errFloorChanged
+			}
+			return services.YieldFloor(queue)
+		})
+		if err != nil && err != errFloorChanged {
+			log.Printf("Error expiring speaking time: %v", err)
+		}
+	})
+}
+
+func (c *Client) handleYieldFloor(req models.WSRequest) (any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p motionPayload
+	motion, err := c.loadFloorMotion(ctx, req, &p, services.CapDebate)
+	if err != nil {
+		return nil, err
+	}
+
+	presiding := c.authorizeCommittee(motion.CommitteeID, services.CapPreside) == nil
+
+	queue, err := c.hub.UpdateSpeakingQueue(ctx, motion, "floor_yielded", func(queue *models.SpeakingQueue) error {
+		if queue.Speaker != nil && queue.Speaker.UserID != c.userID && !presiding {
+			return services.ErrNotSpeaker
+		}
+		return services.YieldFloor(queue)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"queue": queue}, nil
+}
+
+// loadFloorMotion decodes a floor action into p and loads the motion it
+// names, checking the sender may act on it and that the floor is open.
+func (c *Client) loadFloorMotion(ctx context.Context, req models.WSRequest, p floorPayload, capability services.Capability) (*models.Motion, error) {
+	if err := decodePayload(req, p); err != nil {
+		return nil, err
+	}
+
+	motion, err := services.GetMotion(ctx, p.motionID())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.authorizeCommittee(motion.CommitteeID, capability); err != nil {
+		return nil, err
+	}
+
+	if !services.FloorOpen(motion) {
+		return nil, services.ErrFloorClosed
+	}
+
+	return motion, nil
+}
+
+// queueTarget is the member a queue action names, or the sender if it names
+// nobody.
+func (c *Client) queueTarget(p queuePayload) primitive.ObjectID {
+	if id := optionalID(p.UserID); id != nil {
+		return *id
+	}
+	return c.userID
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConnectedUsers lists the users with at least one connection in the
+// committee's room on any instance. Users who hide their online status are
+// included, marked hidden, since they still count toward quorum.
+func (h *Hub) ConnectedUsers(committeeID primitive.ObjectID) []services.ConnectedUser {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+
+	users := []services.ConnectedUser{}
+	for userID, state := range h.presence[models.CreateCommitteeRoomID(committeeID)] {
+		users = append(users, services.ConnectedUser{UserID: userID, Hidden: state.hidden})
+	}
+	return users
+}
+
+// RefreshQuorum recounts the committee's quorum and tells the room when the
+// count or the outcome has changed since the meeting's last announcement.
+func (h *Hub) RefreshQuorum(committeeID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, err := services.CommitteeQuorum(ctx, committeeID)
+	if err != nil {
+		log.Printf("Error counting quorum: %v", err)
+		return
+	}
+
+	if status == nil {
+		return
+	}
+
+	last, changed, err := services.AnnounceQuorum(ctx, status)
+	if err != nil {
+		log.Printf("Error recording quorum: %v", err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	action := "quorum_changed"
+	switch {
+	case last != nil && last.HasQuorum && !status.HasQuorum:
+		action = "quorum_lost"
+	case last != nil && !last.HasQuorum && status.HasQuorum:
+		action = "quorum_restored"
+	}
+
+	h.BroadcastToRoom(models.CreateCommitteeRoomID(committeeID), models.WSMessage{
+		Action:  action,
+		Type:    models.TypeSystem,
+		Payload: map[string]any{"quorum": status},
+	})
+}
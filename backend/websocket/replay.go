@@ -0,0 +1,187 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/zach-short/final-web-programming/models"
+)
+
+const (
+	// replayBufferSize is how many recent events each room keeps for clients
+	// that reconnect.
+	replayBufferSize = 256
+
+	// historyTTL is how long an empty room's events are kept before the
+	// history is dropped.
+	historyTTL   = 15 * time.Minute
+	historySweep = time.Minute
+
+	maxResumeRooms = 50
+)
+
+// roomHistory keeps the most recent of a room's events in a ring, under the
+// numbers the broker gave them, so the event with sequence s sits at
+// (s-1) % replayBufferSize. It holds the events after start up to seq, all
+// from one epoch.
+type roomHistory struct {
+	epoch      string
+	start      uint64
+	seq        uint64
+	events     [][]byte
+	lastActive time.Time
+}
+
+func newRoomHistory() *roomHistory {
+	return &roomHistory{
+		events:     make([][]byte, replayBufferSize),
+		lastActive: time.Now(),
+	}
+}
+
+// record keeps an event under its stamp. An event from a new epoch, or one
+// after numbers this hub never received, starts the history over from it,
+// since nothing before it can be replayed without a gap.
+func (r *roomHistory) record(stamp Stamp, data []byte) {
+	if stamp.Epoch != r.epoch || stamp.Seq != r.seq+1 {
+		r.epoch = stamp.Epoch
+		r.start = stamp.Seq - 1
+	}
+	r.seq = stamp.Seq
+	r.events[(r.seq-1)%replayBufferSize] = data
+	r.lastActive = time.Now()
+}
+
+// since returns the events after seq, or false if some of them were never
+// received or have already been overwritten.
+func (r *roomHistory) since(seq uint64) ([][]byte, bool) {
+	if seq > r.seq || seq < r.start {
+		return nil, false
+	}
+	if r.seq-seq > replayBufferSize {
+		return nil, false
+	}
+
+	missed := make([][]byte, 0, r.seq-seq)
+	for s := seq + 1; s <= r.seq; s++ {
+		missed = append(missed, r.events[(s-1)%replayBufferSize])
+	}
+	return missed, true
+}
+
+// historyOf is called with the hub mutex held.
+func (h *Hub) historyOf(roomID string) *roomHistory {
+	history, ok := h.history[roomID]
+	if !ok {
+		history = newRoomHistory()
+		h.history[roomID] = history
+	}
+	return history
+}
+
+// seedHistory starts the history of a room this hub has not seen an event
+// in at the room's current position, so the cursors it issues for the room
+// hold on every hub. Without the broker the cursor has no epoch and resuming
+// from it resyncs.
+func (h *Hub) seedHistory(roomID string) {
+	h.mutex.RLock()
+	history, ok := h.history[roomID]
+	seeded := ok && history.epoch != ""
+	h.mutex.RUnlock()
+	if seeded {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	position, err := h.broker.Position(ctx, roomID)
+	if err != nil {
+		log.Printf("Error reading the position of room %s: %v", roomID, err)
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// An event may have arrived meanwhile and seeded it already.
+	history = h.historyOf(roomID)
+	if history.epoch == "" {
+		history.epoch = position.Epoch
+		history.start, history.seq = position.Seq, position.Seq
+	}
+}
+
+// cursor is called with the hub mutex held.
+func (h *Hub) cursor(roomID string) models.RoomCursor {
+	history := h.historyOf(roomID)
+	return models.RoomCursor{RoomID: roomID, Epoch: history.epoch, Seq: history.seq}
+}
+
+// ResumeRoom puts a reconnecting client back in a room and queues the events
+// it missed since its cursor, which may have been issued by any instance.
+// Joining and replaying happen under one lock so nothing broadcast in
+// between is lost or sent twice. A client whose cursor is from another
+// epoch, or is further behind than this hub remembers, is told to refetch
+// instead.
+func (h *Hub) ResumeRoom(client *Client, last models.RoomCursor) models.WSResume {
+	h.seedHistory(last.RoomID)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.joinRoom(client, last.RoomID)
+	history := h.historyOf(last.RoomID)
+	result := models.WSResume{RoomCursor: h.cursor(last.RoomID)}
+
+	var missed [][]byte
+	ok := last.Epoch != "" && last.Epoch == history.epoch
+	if ok {
+		missed, ok = history.since(last.Seq)
+	}
+	if ok && len(missed) > cap(client.send)-len(client.send) {
+		ok = false
+	}
+	if !ok {
+		result.Resync = true
+		client.sendFrame(models.WSMessage{
+			Action:  "resync_required",
+			Type:    models.TypeSystem,
+			RoomID:  last.RoomID,
+			Payload: result.RoomCursor,
+		})
+		return result
+	}
+
+	for _, data := range missed {
+		if !client.enqueue(data) {
+			log.Printf("Replay for client %s in room %s overflowed", client.userID.Hex(), last.RoomID)
+			result.Resync = true
+			break
+		}
+		result.Replayed++
+	}
+	return result
+}
+
+// touchHistory restarts the TTL of a room's history when its last client
+// leaves. It is called with the hub mutex held.
+func (h *Hub) touchHistory(roomID string) {
+	if history, ok := h.history[roomID]; ok {
+		history.lastActive = time.Now()
+	}
+}
+
+// sweepHistory forgets the events of rooms that have been empty and quiet
+// for historyTTL.
+func (h *Hub) sweepHistory() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	cutoff := time.Now().Add(-historyTTL)
+	for roomID, history := range h.history {
+		if len(h.rooms[roomID]) == 0 && history.lastActive.Before(cutoff) {
+			delete(h.history, roomID)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zach-short/final-web-programming/config"
+	"github.com/zach-short/final-web-programming/models"
+	"github.com/zach-short/final-web-programming/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	errNotCommitteeRoom = errors.New("action is only available in committee rooms")
+	errNotParticipant   = errors.New("not a participant in this room")
+	errUnknownRoom      = errors.New("unknown room")
+	errReceiveOnly      = errors.New("observers can follow this room but cannot post in it")
+	errInvalidRoom      = errors.New("a room ID is required")
+	errParentRoom       = errors.New("the message being replied to is not in this room")
+	errNotInRoom        = errors.New("join the room first")
+)
+
+// AuthorizeRoom decides whether a user may use a room. Joining needs
+// CapViewCommittee and posting needs CapDebate. DM rooms admit only the two
+// users in the room ID, group rooms only their recorded participants, and
+// committee rooms the roster, where observers may join but not post.
+func AuthorizeRoom(userID primitive.ObjectID, roomID string, capability services.Capability) error {
+	if committeeID, ok := models.ParseCommitteeRoomID(roomID); ok {
+		err := authorizeCommittee(userID, committeeID, capability)
+		if errors.Is(err, services.ErrPermissionDenied) && capability == services.CapDebate {
+			return errReceiveOnly
+		}
+		return err
+	}
+
+	if capability != services.CapViewCommittee && capability != services.CapDebate {
+		return errNotCommitteeRoom
+	}
+
+	if first, second, ok := models.ParseDMRoomID(roomID); ok {
+		if userID != first && userID != second {
+			return errNotParticipant
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(roomID, "group_") {
+		return authorizeGroup(userID, roomID)
+	}
+	return errUnknownRoom
+}
+
+func (c *Client) authorizeRoom(roomID string, capability services.Capability) error {
+	return AuthorizeRoom(c.userID, roomID, capability)
+}
+
+func (c *Client) authorizeCommittee(committeeID primitive.ObjectID, capability services.Capability) error {
+	return authorizeCommittee(c.userID, committeeID, capability)
+}
+
+func authorizeCommittee(userID, committeeID primitive.ObjectID, capability services.Capability) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, _, err := services.Authorize(ctx, committeeID, userID, capability)
+	return err
+}
+
+func authorizeGroup(userID primitive.ObjectID, roomID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := config.GetCollection("rooms").FindOne(ctx, bson.M{
+		"_id":          roomID,
+		"type":         models.RoomTypeGroup,
+		"participants": userID,
+	}).Err()
+	if err == mongo.ErrNoDocuments {
+		return errNotParticipant
+	}
+	return err
+}
+
+// RemoveUserFromRoom drops every connection a user has open in a room, on
+// any instance, for when they lose access to it, and tells them why.
+func (h *Hub) RemoveUserFromRoom(userID primitive.ObjectID, roomID string) {
+	err := h.publish(roomEvent{Kind: roomEventRevoke, RoomID: roomID, UserID: &userID})
+	if err != nil {
+		log.Printf("Error publishing room revocation, revoking locally: %v", err)
+		h.revoke(userID, roomID)
+	}
+}
+
+func (h *Hub) revoke(userID primitive.ObjectID, roomID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for client := range h.rooms[roomID] {
+		if client.userID != userID {
+			continue
+		}
+		h.removeFromRoom(client, roomID)
+		client.sendFrame(models.WSMessage{
+			Action: "room_access_revoked",
+			Type:   models.TypeSystem,
+			Payload: map[string]any{
+				"roomId": roomID,
+			},
+		})
+	}
+}
+
+// CloseRoom drops every connection in a room, on any instance, for when the
+// room itself is gone.
+func (h *Hub) CloseRoom(roomID string) {
+	err := h.publish(roomEvent{Kind: roomEventClose, RoomID: roomID})
+	if err != nil {
+		log.Printf("Error publishing room close, closing locally: %v", err)
+		h.closeRoom(roomID)
+	}
+}
+
+func (h *Hub) closeRoom(roomID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for client := range h.rooms[roomID] {
+		h.removeFromRoom(client, roomID)
+		client.sendFrame(models.WSMessage{
+			Action: "room_closed",
+			Type:   models.TypeSystem,
+			Payload: map[string]any{
+				"roomId": roomID,
+			},
+		})
+	}
+}
+
+// checkParentMessage keeps replies in the parent's room, so a reply cannot be
+// used to reach into a room the sender was not authorized for.
+func (c *Client) checkParentMessage(parentID primitive.ObjectID, roomID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := config.GetCollection("messages").FindOne(ctx, bson.M{"_id": parentID, "roomId": roomID}).Err()
+	if err == mongo.ErrNoDocuments {
+		return errParentRoom
+	}
+	return err
+}
+
+func (h *Hub) inRoom(client *Client, roomID string) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.rooms[roomID][client]
+}